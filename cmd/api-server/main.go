@@ -3,6 +3,7 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -10,6 +11,8 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"runtime"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -18,26 +21,45 @@ import (
 )
 
 var (
-	port     = flag.Int("port", 8080, "API服务端口")
-	tempDir  = flag.String("temp-dir", "", "临时目录路径，为空则使用默认路径")
-	execTime = flag.Int("exec-timeout", 3, "执行超时时间（秒）")
-	languages = flag.String("languages", "go,cpp,python,java,javascript", "支持的语言列表（逗号分隔）")
+	port           = flag.Int("port", 8080, "API服务端口")
+	tempDir        = flag.String("temp-dir", "", "临时目录路径，为空则使用默认路径")
+	execTime       = flag.Int("exec-timeout", 3, "执行超时时间（秒）")
+	languages      = flag.String("languages", "go,cpp,python,java,javascript", "支持的语言列表（逗号分隔）")
+	workers        = flag.Int("workers", runtime.NumCPU(), "异步提交的并发执行数")
+	queueSize      = flag.Int("queue-size", 100, "异步提交队列容量，队列满时拒绝新提交")
+	jobTTL         = flag.Duration("job-ttl", 10*time.Minute, "已完成提交的结果保留时长")
+	maxCompiles    = flag.Int("max-compiles", runtime.NumCPU(), "沙箱内同时进行的最大编译数（0为不限制）")
+	maxExecutions  = flag.Int("max-executions", runtime.NumCPU(), "沙箱内同时运行的最大用户程序数（0为不限制）")
+	pinCPUs        = flag.String("pin-cpus", "", "为每个执行槽位绑定的CPU核心（逗号分隔，如 2,3,4,5）")
+	runUIDBase     = flag.Int("run-uid-base", sandbox.DefaultRunUIDBase, "运行用户程序的起始UID/GID，每个执行槽位使用两个（用户程序和检查器各一个），每个会话槽位使用一个，要求 max-executions 和 session-executions 不为0（0为不切换用户）")
+	problemsDir    = flag.String("problems-dir", "", "题目包目录（每个子目录包含一个problem.yaml），为空则不启用")
+	problemsReload = flag.Duration("problems-reload", 5*time.Second, "检查题目包变化的间隔（0为不热加载）")
+	maxSessions    = flag.Int("max-sessions", 16, "同时存在的交互式会话上限（0为不限制）")
+	sessionRuns    = flag.Int("session-executions", runtime.NumCPU(), "同时运行的交互式会话程序上限，使用与判题分开的执行槽位（0为不限制）")
+	sessionIdle    = flag.Duration("session-idle", time.Minute, "交互式会话无输入且无事件流连接时的回收时间")
+	sessionTimeout = flag.Duration("session-timeout", 5*time.Minute, "交互式会话程序的墙钟时间上限")
 )
 
+// executeRequest 执行请求，可以通过 problemId 引用服务端的题目包
+type executeRequest struct {
+	sandbox.Request
+	ProblemID string `json:"problemId,omitempty"`
+}
+
 func main() {
 	flag.Parse()
-	
+
 	// 设置日志格式
 	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
 	log.Printf("启动 croj-sandbox API 服务 (端口: %d)", *port)
-	
+
 	// 解析支持的语言列表
 	supportedLangs := strings.Split(*languages, ",")
 	for i, lang := range supportedLangs {
 		supportedLangs[i] = strings.TrimSpace(lang)
 	}
 	log.Printf("支持的编程语言: %v", supportedLangs)
-	
+
 	// 创建自定义配置
 	cfg := sandbox.DefaultConfig()
 	if *tempDir != "" {
@@ -45,68 +67,232 @@ func main() {
 	}
 	cfg.DefaultExecuteTimeLimit = time.Duration(*execTime) * time.Second
 	cfg.ExecTimeout = time.Duration(*execTime) * time.Second // 兼容字段
-	
+	cfg.MaxParallelCompiles = *maxCompiles
+	cfg.MaxParallelExecutions = *maxExecutions
+	cfg.MaxParallelSessions = *sessionRuns
+	cfg.RunUIDBase = *runUIDBase
+	cfg.RunGIDBase = *runUIDBase
+	if *pinCPUs != "" {
+		for _, c := range strings.Split(*pinCPUs, ",") {
+			cpu, err := strconv.Atoi(strings.TrimSpace(c))
+			if err != nil {
+				log.Fatalf("无效的CPU编号 %q: %v", c, err)
+			}
+			cfg.ExecutionCPUs = append(cfg.ExecutionCPUs, cpu)
+		}
+	}
+
 	// 初始化API
 	api, err := sandbox.NewSandboxAPIWithConfig(cfg)
 	if err != nil {
 		log.Fatalf("初始化API失败: %v", err)
 	}
 	defer api.Close()
-	
+
+	// 加载题目包
+	var problems *ProblemRegistry
+	if *problemsDir != "" {
+		problems, err = NewProblemRegistry(*problemsDir, *problemsReload)
+		if err != nil {
+			log.Fatalf("加载题目包失败: %v", err)
+		}
+		defer problems.Close()
+	}
+
+	// 创建异步提交队列
+	queue := NewSubmissionQueue(api, *workers, *queueSize, *jobTTL)
+	defer queue.Close()
+
+	// 创建交互式会话管理器
+	sessions := NewSessionManager(api, *maxSessions, *sessionRuns, *sessionIdle, *sessionTimeout)
+	defer sessions.Close()
+
 	// 创建HTTP处理器
 	http.HandleFunc("/execute", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "仅支持POST请求", http.StatusMethodNotAllowed)
 			return
 		}
-		
-		// 读取请求体
-		body, err := io.ReadAll(r.Body)
+
+		req, ok := readRequest(w, r, supportedLangs, problems)
+		if !ok {
+			return
+		}
+
+		// 执行代码
+		response := api.Execute(req)
+
+		// 返回结果
+		writeJSON(w, http.StatusOK, response)
+	})
+
+	// 流式执行: POST /execute/stream 以Server-Sent Events实时返回输出和最终结果
+	http.HandleFunc("/execute/stream", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "仅支持POST请求", http.StatusMethodNotAllowed)
+			return
+		}
+
+		req, ok := readRequest(w, r, supportedLangs, problems)
+		if !ok {
+			return
+		}
+		serveStream(w, r, api, req)
+	})
+
+	// 异步提交: POST /submissions 返回任务ID
+	http.HandleFunc("/submissions", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "仅支持POST请求", http.StatusMethodNotAllowed)
+			return
+		}
+
+		req, ok := readRequest(w, r, supportedLangs, problems)
+		if !ok {
+			return
+		}
+
+		job, err := queue.Submit(req)
 		if err != nil {
-			http.Error(w, "读取请求失败", http.StatusBadRequest)
+			http.Error(w, fmt.Sprintf("提交失败: %v", err), http.StatusServiceUnavailable)
+			return
+		}
+
+		stats := queue.Stats()
+		writeJSON(w, http.StatusAccepted, map[string]interface{}{
+			"id":          job.ID,
+			"status":      job.Status,
+			"queueLength": stats.Queued,
+		})
+	})
+
+	// 查询提交: GET /submissions/{id}, 取消提交: DELETE /submissions/{id}
+	http.HandleFunc("/submissions/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/submissions/")
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodDelete:
+			done, err := queue.Cancel(id)
+			switch {
+			case errors.Is(err, ErrJobNotFound):
+				http.Error(w, fmt.Sprintf("提交不存在: %s", id), http.StatusNotFound)
+				return
+			case errors.Is(err, ErrJobFinished):
+				http.Error(w, fmt.Sprintf("提交已执行完成: %s", id), http.StatusConflict)
+				return
+			}
+			// 等待编译或用户程序被终止，返回最终结果
+			select {
+			case <-done:
+			case <-r.Context().Done():
+				return
+			}
+		default:
+			http.Error(w, "仅支持GET和DELETE请求", http.StatusMethodNotAllowed)
 			return
 		}
-		
-		// 解析请求参数
-		var req sandbox.Request
-		if err := json.Unmarshal(body, &req); err != nil {
-			http.Error(w, "无效的JSON格式", http.StatusBadRequest)
+
+		job, ok := queue.Get(id)
+		if !ok {
+			http.Error(w, fmt.Sprintf("提交不存在: %s", id), http.StatusNotFound)
 			return
 		}
-		
-		 // 验证语言是否支持
-		if req.Language != "" {
-			langSupported := false
-			for _, lang := range supportedLangs {
-				if req.Language == lang {
-					langSupported = true
-					break
-				}
+		writeJSON(w, http.StatusOK, job)
+	})
+
+	// 交互式会话: POST /sessions 编译并启动程序，返回会话ID
+	http.HandleFunc("/sessions", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "仅支持POST请求", http.StatusMethodNotAllowed)
+			return
+		}
+
+		req, ok := readRequest(w, r, supportedLangs, problems)
+		if !ok {
+			return
+		}
+		if len(req.TestCases) > 0 || req.Interactor != nil {
+			http.Error(w, "交互式会话不支持测试用例和交互器", http.StatusBadRequest)
+			return
+		}
+
+		session, err := sessions.Start(req)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("创建会话失败: %v", err), http.StatusServiceUnavailable)
+			return
+		}
+		writeJSON(w, http.StatusCreated, map[string]string{"id": session.ID})
+	})
+
+	// 会话操作: POST /sessions/{id}/stdin 写入输入, GET /sessions/{id}/events 读取输出事件流,
+	// GET /sessions/{id} 查询结果, DELETE /sessions/{id} 终止会话
+	http.HandleFunc("/sessions/", func(w http.ResponseWriter, r *http.Request) {
+		id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/sessions/"), "/")
+		session, ok := sessions.Get(id)
+		if !ok {
+			http.Error(w, fmt.Sprintf("会话不存在: %s", id), http.StatusNotFound)
+			return
+		}
+
+		switch {
+		case action == "stdin" && r.Method == http.MethodPost:
+			var body struct {
+				Data string `json:"data"`
+				EOF  bool   `json:"eof"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				http.Error(w, fmt.Sprintf("无效的请求格式: %v", err), http.StatusBadRequest)
+				return
 			}
-			
-			if !langSupported {
-				http.Error(w, fmt.Sprintf("不支持的编程语言: %s", req.Language), http.StatusBadRequest)
+			if err := session.Write(body.Data, body.EOF); err != nil {
+				status := http.StatusConflict
+				if errors.Is(err, ErrInputQueueFull) {
+					status = http.StatusTooManyRequests
+				}
+				http.Error(w, err.Error(), status)
 				return
 			}
-		} else {
-			// 默认使用Go语言
-			req.Language = "go"
+			w.WriteHeader(http.StatusNoContent)
+		case action == "events" && r.Method == http.MethodGet:
+			serveSessionEvents(w, r, session)
+		case action == "" && r.Method == http.MethodGet:
+			writeJSON(w, http.StatusOK, map[string]interface{}{
+				"id":     session.ID,
+				"result": session.Result(),
+			})
+		case action == "" && r.Method == http.MethodDelete:
+			sessions.Remove(id)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "不支持的会话操作", http.StatusMethodNotAllowed)
 		}
-		
-		// 执行代码
-		response := api.Execute(req)
-		
-		// 返回结果
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
 	})
-	
+
+	// 题目包列表: GET /problems
+	http.HandleFunc("/problems", func(w http.ResponseWriter, r *http.Request) {
+		if problems == nil {
+			writeJSON(w, http.StatusOK, []*Problem{})
+			return
+		}
+		writeJSON(w, http.StatusOK, problems.List())
+	})
+
+	// 队列状态: GET /queue
+	http.HandleFunc("/queue", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, queue.Stats())
+	})
+
+	// 沙箱并发状态: GET /stats (编译/执行槽位占用和等待时间)
+	http.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, api.Stats())
+	})
+
 	// 添加健康检查端点
 	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		fmt.Fprintf(w, "API服务正常运行中")
 	})
-	
+
 	// 添加语言列表端点
 	http.HandleFunc("/languages", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -114,14 +300,14 @@ func main() {
 			"languages": supportedLangs,
 		})
 	})
-	
+
 	// 启动服务器
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%d", *port),
 		ReadTimeout:  30 * time.Second,
 		WriteTimeout: 30 * time.Second,
 	}
-	
+
 	// 优雅关闭
 	go func() {
 		sigChan := make(chan os.Signal, 1)
@@ -130,18 +316,85 @@ func main() {
 		log.Println("接收到关闭信号，停止服务...")
 		server.Close()
 	}()
-	
+
 	// 启动HTTP服务
 	log.Printf("API服务器运行在 http://localhost:%d", *port)
 	log.Printf("可用端点:")
 	log.Printf("  /execute - 执行代码")
+	log.Printf("  /execute/stream - 执行代码，以Server-Sent Events实时返回输出")
+	log.Printf("  /submissions - 异步提交 (POST), /submissions/{id} - 查询结果 (GET) / 取消 (DELETE)")
+	log.Printf("  /sessions - 交互式会话 (POST), /sessions/{id}/stdin, /sessions/{id}/events, /sessions/{id}")
+	log.Printf("  /problems - 查询已加载的题目包")
+	log.Printf("  /queue - 查询队列状态")
+	log.Printf("  /stats - 查询沙箱并发槽位和等待时间")
 	log.Printf("  /health  - 健康检查")
 	log.Printf("  /languages - 查询支持的语言列表")
 	log.Printf("示例请求: curl -X POST http://localhost:%d/execute -H \"Content-Type: application/json\" -d '{\"language\":\"go\",\"sourceCode\":\"package main\\nimport \\\"fmt\\\"\\nfunc main() {\\n  fmt.Println(\\\"Hello API\\\")\\n}\"}'", *port)
-	
+
 	if err := server.ListenAndServe(); err != http.ErrServerClosed {
 		log.Fatalf("HTTP服务器错误: %v", err)
 	}
-	
+
 	log.Println("API服务器已成功关闭")
 }
+
+// readRequest 读取并校验执行请求，失败时写入错误响应并返回false
+// 请求引用题目包时，用题目包的设置和测试数据填充请求
+func readRequest(w http.ResponseWriter, r *http.Request, supportedLangs []string, problems *ProblemRegistry) (sandbox.Request, bool) {
+	var body executeRequest
+	req := &body.Request
+
+	// 读取请求体
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "读取请求失败", http.StatusBadRequest)
+		return *req, false
+	}
+
+	// 解析请求参数
+	if err := json.Unmarshal(data, &body); err != nil {
+		http.Error(w, "无效的JSON格式", http.StatusBadRequest)
+		return *req, false
+	}
+
+	// 验证语言是否支持
+	if req.Language != "" {
+		langSupported := false
+		for _, lang := range supportedLangs {
+			if req.Language == lang {
+				langSupported = true
+				break
+			}
+		}
+
+		if !langSupported {
+			http.Error(w, fmt.Sprintf("不支持的编程语言: %s", req.Language), http.StatusBadRequest)
+			return *req, false
+		}
+	} else {
+		// 默认使用Go语言
+		req.Language = "go"
+	}
+
+	if body.ProblemID != "" {
+		if problems == nil {
+			http.Error(w, "未启用题目包", http.StatusBadRequest)
+			return *req, false
+		}
+		problem, err := problems.Get(body.ProblemID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("题目不存在: %s", body.ProblemID), http.StatusNotFound)
+			return *req, false
+		}
+		problem.Apply(req)
+	}
+
+	return *req, true
+}
+
+// writeJSON 以JSON格式写入响应
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
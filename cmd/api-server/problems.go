@@ -0,0 +1,438 @@
+// cmd/api-server/problems.go
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CodeRushOJ/croj-sandbox/internal/sandbox"
+	"gopkg.in/yaml.v3"
+)
+
+// problemFileName 题目包的描述文件名
+const problemFileName = "problem.yaml"
+
+// ErrProblemNotFound 请求引用的题目不存在
+var ErrProblemNotFound = errors.New("problem not found")
+
+// problemSpec 对应 problem.yaml 的内容，所有文件路径都相对于题目包目录
+type problemSpec struct {
+	TimeLimit      *int `yaml:"timeLimit"`      // CPU时间限制（秒）
+	WallTimeLimit  *int `yaml:"wallTimeLimit"`  // 墙钟时间限制（秒）
+	MemoryLimit    *int `yaml:"memoryLimit"`    // 内存限制（MB）
+	StackLimit     *int `yaml:"stackLimit"`     // 栈大小限制（MB），-1 表示不限制
+	FileSizeLimit  *int `yaml:"fileSizeLimit"`  // 写入文件大小限制（MB）
+	OpenFilesLimit *int `yaml:"openFilesLimit"` // 打开文件数限制
+	ProcessLimit   *int `yaml:"processLimit"`   // 进程/线程数限制
+
+	// 文件输入输出：设置后测试数据写入该文件而不是标准输入，期望输出与该输出文件比较
+	InputFile  string `yaml:"inputFile"`
+	OutputFile string `yaml:"outputFile"`
+
+	Checker *struct {
+		Type       string  `yaml:"type"`
+		AbsEpsilon float64 `yaml:"absEpsilon"`
+		RelEpsilon float64 `yaml:"relEpsilon"`
+		Language   string  `yaml:"language"`
+		Source     string  `yaml:"source"` // 自定义检查器源文件
+	} `yaml:"checker"`
+
+	Interactor *struct {
+		Language         string `yaml:"language"`
+		Source           string `yaml:"source"`
+		RecordTranscript bool   `yaml:"recordTranscript"`
+	} `yaml:"interactor"`
+
+	Grader *struct {
+		SrcName        string   `yaml:"srcName"`
+		CompileCommand string   `yaml:"compileCommand"`
+		Files          []string `yaml:"files"` // 复制到运行目录时保留相对路径
+	} `yaml:"grader"`
+
+	// 测试用例，为空时使用 tests 目录下同名的 .in 与 .out/.ans 文件
+	TestCases []struct {
+		Input       string `yaml:"input"`
+		Output      string `yaml:"output"`
+		Score       int    `yaml:"score"`
+		Sample      bool   `yaml:"sample"`
+		Timeout     *int   `yaml:"timeout"`
+		MemoryLimit *int   `yaml:"memoryLimit"`
+	} `yaml:"testCases"`
+
+	// 子任务，cases 和 dependsOn 为从0开始的测试用例和子任务下标
+	Subtasks []struct {
+		Name          string `yaml:"name"`
+		Score         int    `yaml:"score"`
+		Cases         []int  `yaml:"cases"`
+		Scoring       string `yaml:"scoring"`
+		DependsOn     []int  `yaml:"dependsOn"`
+		StopOnFailure bool   `yaml:"stopOnFailure"`
+	} `yaml:"subtasks"`
+}
+
+// Problem 已加载的题目包，加载后只读
+type Problem struct {
+	ID       string    `json:"id"`
+	Cases    int       `json:"testCases"`
+	LoadedAt time.Time `json:"loadedAt"`
+
+	template    sandbox.Request // 题目决定的请求字段
+	fingerprint string
+}
+
+// Apply 用题目包中的限制、检查器、交互器、评测附加文件和测试数据填充请求。
+// 这些字段完全由题目包决定：请求中的同名字段被覆盖，题目包未设置的限制使用服务端默认值
+func (p *Problem) Apply(req *sandbox.Request) {
+	t := p.template
+	req.Stdin, req.ExpectedOutput = nil, nil
+	req.TestCases = append([]sandbox.TestCase(nil), t.TestCases...)
+	req.Subtasks, req.RunPolicy = t.Subtasks, t.RunPolicy
+	req.Checker, req.Interactor, req.Grader = t.Checker, t.Interactor, t.Grader
+	req.InputFiles, req.OutputFiles, req.ExpectedOutputFiles = t.InputFiles, t.OutputFiles, t.ExpectedOutputFiles
+	req.Timeout, req.WallTimeout, req.MemoryLimit = t.Timeout, t.WallTimeout, t.MemoryLimit
+	req.StackLimit, req.FileSizeLimit = t.StackLimit, t.FileSizeLimit
+	req.OpenFilesLimit, req.ProcessLimit = t.OpenFilesLimit, t.ProcessLimit
+}
+
+// ProblemRegistry 从本地目录加载题目包（每个子目录一个题目，目录名即题目ID），
+// 并定期检查目录变化，重新加载修改过的题目
+type ProblemRegistry struct {
+	dir string
+
+	mu       sync.RWMutex
+	problems map[string]*Problem
+	failed   map[string]string // 加载失败的题目ID -> 目录指纹，目录不变时不再重试
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewProblemRegistry 加载 dir 下的所有题目包；interval > 0 时按该间隔热加载
+func NewProblemRegistry(dir string, interval time.Duration) (*ProblemRegistry, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("problem directory: %w", err)
+	}
+	r := &ProblemRegistry{
+		dir:      dir,
+		problems: make(map[string]*Problem),
+		failed:   make(map[string]string),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	r.reload()
+	if interval > 0 {
+		go r.watch(interval)
+	} else {
+		close(r.done)
+	}
+	log.Printf("题目包已加载: %d 个 (目录 %s)", len(r.problems), dir)
+	return r, nil
+}
+
+// Get 返回题目ID对应的题目包
+func (r *ProblemRegistry) Get(id string) (*Problem, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.problems[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrProblemNotFound, id)
+	}
+	return p, nil
+}
+
+// List 返回所有已加载的题目，按ID排序
+func (r *ProblemRegistry) List() []*Problem {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	list := make([]*Problem, 0, len(r.problems))
+	for _, p := range r.problems {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// Close 停止热加载
+func (r *ProblemRegistry) Close() {
+	select {
+	case <-r.stop:
+	default:
+		close(r.stop)
+	}
+	<-r.done
+}
+
+// watch 定期扫描题目目录
+func (r *ProblemRegistry) watch(interval time.Duration) {
+	defer close(r.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.reload()
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// reload 重新加载内容发生变化的题目包，删除已不存在的题目。
+// 加载失败的题目保留上一次成功加载的版本
+func (r *ProblemRegistry) reload() {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		log.Printf("读取题目目录失败: %v", err)
+		return
+	}
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		id := entry.Name()
+		dir := filepath.Join(r.dir, id)
+		if _, err := os.Stat(filepath.Join(dir, problemFileName)); err != nil {
+			continue
+		}
+		seen[id] = true
+
+		fingerprint, err := dirFingerprint(dir)
+		if err != nil {
+			log.Printf("扫描题目 %s 失败: %v", id, err)
+			continue
+		}
+		r.mu.RLock()
+		old := r.problems[id]
+		r.mu.RUnlock()
+		if old != nil && old.fingerprint == fingerprint || r.failed[id] == fingerprint {
+			continue
+		}
+
+		p, err := loadProblem(id, dir)
+		if err != nil {
+			log.Printf("加载题目 %s 失败: %v", id, err)
+			r.failed[id] = fingerprint
+			continue
+		}
+		delete(r.failed, id)
+		p.fingerprint = fingerprint
+		r.mu.Lock()
+		r.problems[id] = p
+		r.mu.Unlock()
+		if old != nil {
+			log.Printf("题目 %s 已重新加载 (%d 个测试用例)", id, p.Cases)
+		}
+	}
+
+	r.mu.Lock()
+	for id := range r.problems {
+		if !seen[id] {
+			delete(r.problems, id)
+			log.Printf("题目 %s 已移除", id)
+		}
+	}
+	r.mu.Unlock()
+	for id := range r.failed {
+		if !seen[id] {
+			delete(r.failed, id)
+		}
+	}
+}
+
+// dirFingerprint 根据目录下所有文件的路径、大小和修改时间计算指纹
+func dirFingerprint(dir string) (string, error) {
+	h := sha256.New()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		fmt.Fprintf(h, "%s\x00%d\x00%d\x00", rel, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// loadProblem 解析题目包目录
+func loadProblem(id, dir string) (*Problem, error) {
+	data, err := os.ReadFile(filepath.Join(dir, problemFileName))
+	if err != nil {
+		return nil, err
+	}
+	var spec problemSpec
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("%s: %w", problemFileName, err)
+	}
+	read := func(name string) (string, error) {
+		if !filepath.IsLocal(name) {
+			return "", fmt.Errorf("path %q is outside the problem directory", name)
+		}
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		return string(content), err
+	}
+
+	t := sandbox.Request{
+		Timeout:        spec.TimeLimit,
+		WallTimeout:    spec.WallTimeLimit,
+		MemoryLimit:    spec.MemoryLimit,
+		StackLimit:     spec.StackLimit,
+		FileSizeLimit:  spec.FileSizeLimit,
+		OpenFilesLimit: spec.OpenFilesLimit,
+		ProcessLimit:   spec.ProcessLimit,
+	}
+	if c := spec.Checker; c != nil {
+		t.Checker = &sandbox.CheckerSpec{Type: c.Type, AbsEpsilon: c.AbsEpsilon, RelEpsilon: c.RelEpsilon, Language: c.Language}
+		if c.Source != "" {
+			if t.Checker.SourceCode, err = read(c.Source); err != nil {
+				return nil, fmt.Errorf("checker: %w", err)
+			}
+		}
+	}
+	if it := spec.Interactor; it != nil {
+		t.Interactor = &sandbox.InteractorSpec{Language: it.Language, RecordTranscript: it.RecordTranscript}
+		if t.Interactor.SourceCode, err = read(it.Source); err != nil {
+			return nil, fmt.Errorf("interactor: %w", err)
+		}
+	}
+	if g := spec.Grader; g != nil {
+		t.Grader = &sandbox.GraderSpec{SrcName: g.SrcName, CompileCommand: g.CompileCommand, Files: make(map[string]string)}
+		for _, name := range g.Files {
+			if t.Grader.Files[filepath.ToSlash(name)], err = read(name); err != nil {
+				return nil, fmt.Errorf("grader: %w", err)
+			}
+		}
+	}
+	if spec.OutputFile != "" {
+		t.OutputFiles = []string{spec.OutputFile}
+	}
+
+	var paths []casePaths
+	if len(spec.TestCases) > 0 {
+		for _, tc := range spec.TestCases {
+			paths = append(paths, casePaths{input: tc.Input, output: tc.Output, sample: tc.Sample})
+		}
+	} else if paths, err = discoverTestCases(dir); err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, errors.New("no test cases")
+	}
+	for i, cp := range paths {
+		tc := sandbox.TestCase{Sample: cp.sample}
+		if i < len(spec.TestCases) {
+			tc.Score = spec.TestCases[i].Score
+			tc.Timeout = spec.TestCases[i].Timeout
+			tc.MemoryLimit = spec.TestCases[i].MemoryLimit
+		}
+		input, err := read(cp.input)
+		if err != nil {
+			return nil, fmt.Errorf("test case %d: %w", i+1, err)
+		}
+		output, err := read(cp.output)
+		if err != nil {
+			return nil, fmt.Errorf("test case %d: %w", i+1, err)
+		}
+		if spec.InputFile != "" {
+			tc.InputFiles = map[string]string{spec.InputFile: input}
+		} else {
+			tc.Stdin = &input
+		}
+		if spec.OutputFile != "" {
+			tc.ExpectedOutputFiles = map[string]string{spec.OutputFile: output}
+		} else {
+			tc.ExpectedOutput = &output
+		}
+		t.TestCases = append(t.TestCases, tc)
+	}
+
+	for _, st := range spec.Subtasks {
+		t.Subtasks = append(t.Subtasks, sandbox.Subtask{
+			Name: st.Name, Score: st.Score, Cases: st.Cases, Scoring: st.Scoring,
+			DependsOn: st.DependsOn, StopOnFailure: st.StopOnFailure,
+		})
+	}
+
+	return &Problem{ID: id, Cases: len(t.TestCases), LoadedAt: time.Now(), template: t}, nil
+}
+
+// casePaths 一个测试用例的输入和期望输出文件
+type casePaths struct {
+	input, output string
+	sample        bool
+}
+
+// discoverTestCases 查找 tests 目录下的 name.in 与 name.out（或 name.ans），按文件名排序，
+// 文件名以 sample 开头的是样例
+func discoverTestCases(dir string) ([]casePaths, error) {
+	inputs, err := filepath.Glob(filepath.Join(dir, "tests", "*.in"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(inputs, func(i, j int) bool { return naturalLess(inputs[i], inputs[j]) })
+	var cases []casePaths
+	for _, in := range inputs {
+		base := strings.TrimSuffix(in, ".in")
+		out := base + ".out"
+		if _, err := os.Stat(out); err != nil {
+			out = base + ".ans"
+		}
+		if _, err := os.Stat(out); err != nil {
+			return nil, fmt.Errorf("no .out or .ans file for %s", filepath.Base(in))
+		}
+		relIn, _ := filepath.Rel(dir, in)
+		relOut, _ := filepath.Rel(dir, out)
+		cases = append(cases, casePaths{input: relIn, output: relOut, sample: strings.HasPrefix(filepath.Base(in), "sample")})
+	}
+	return cases, nil
+}
+
+// naturalLess 按自然顺序比较文件名，使 2.in 排在 10.in 之前
+func naturalLess(a, b string) bool {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		ca, cb := a[i], b[j]
+		if isDigit(ca) && isDigit(cb) {
+			si, sj := i, j
+			for i < len(a) && isDigit(a[i]) {
+				i++
+			}
+			for j < len(b) && isDigit(b[j]) {
+				j++
+			}
+			na, nb := strings.TrimLeft(a[si:i], "0"), strings.TrimLeft(b[sj:j], "0")
+			if len(na) != len(nb) {
+				return len(na) < len(nb)
+			}
+			if na != nb {
+				return na < nb
+			}
+			continue
+		}
+		if ca != cb {
+			return ca < cb
+		}
+		i++
+		j++
+	}
+	return len(a)-i < len(b)-j
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
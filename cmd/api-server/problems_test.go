@@ -0,0 +1,78 @@
+// cmd/api-server/problems_test.go
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/CodeRushOJ/croj-sandbox/internal/sandbox"
+)
+
+// writeProblem creates a problem package with one test case under dir/id.
+func writeProblem(t *testing.T, dir, id, spec string) {
+	t.Helper()
+	files := map[string]string{
+		problemFileName: spec,
+		"tests/1.in":    "1 2\n",
+		"tests/1.out":   "3\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, id, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestProblemApplyOverridesRequest(t *testing.T) {
+	dir := t.TempDir()
+	writeProblem(t, dir, "aplusb", "memoryLimit: 64\n")
+	registry, err := NewProblemRegistry(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer registry.Close()
+	problem, err := registry.Get("aplusb")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 提交者试图自行指定限制、输入输出文件和运行策略
+	thirty, huge := 30, 4096
+	req := sandbox.Request{
+		SourceCode:          "main",
+		Timeout:             &thirty,
+		WallTimeout:         &thirty,
+		MemoryLimit:         &huge,
+		StackLimit:          &huge,
+		FileSizeLimit:       &huge,
+		OpenFilesLimit:      &huge,
+		ProcessLimit:        &huge,
+		InputFiles:          map[string]string{"input.txt": "x"},
+		ExpectedOutputFiles: map[string]string{"output.txt": "x"},
+		RunPolicy:           sandbox.RunStopOnFailure,
+	}
+	problem.Apply(&req)
+
+	if req.MemoryLimit == nil || *req.MemoryLimit != 64 {
+		t.Errorf("memory limit %v, want the problem's 64 MB", req.MemoryLimit)
+	}
+	for name, limit := range map[string]*int{
+		"timeout": req.Timeout, "wallTimeout": req.WallTimeout, "stackLimit": req.StackLimit,
+		"fileSizeLimit": req.FileSizeLimit, "openFilesLimit": req.OpenFilesLimit, "processLimit": req.ProcessLimit,
+	} {
+		if limit != nil {
+			t.Errorf("%s = %d, want the server default for a limit the problem does not set", name, *limit)
+		}
+	}
+	if req.InputFiles != nil || req.ExpectedOutputFiles != nil || req.RunPolicy != "" {
+		t.Errorf("request file I/O or run policy kept: %v %v %q", req.InputFiles, req.ExpectedOutputFiles, req.RunPolicy)
+	}
+	if len(req.TestCases) != 1 || *req.TestCases[0].ExpectedOutput != "3\n" || req.SourceCode != "main" {
+		t.Errorf("problem data not applied: %+v", req)
+	}
+}
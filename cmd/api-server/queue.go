@@ -0,0 +1,277 @@
+// cmd/api-server/queue.go
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/CodeRushOJ/croj-sandbox/internal/sandbox"
+	"github.com/google/uuid"
+)
+
+// 提交任务状态
+const (
+	JobQueued   = "queued"   // 等待执行
+	JobRunning  = "running"  // 正在执行
+	JobFinished = "finished" // 执行完成，结果可用
+)
+
+// ErrQueueFull 队列已满，提交被拒绝
+var ErrQueueFull = errors.New("submission queue is full")
+
+// ErrQueueClosed 队列已关闭
+var ErrQueueClosed = errors.New("submission queue is closed")
+
+// ErrJobNotFound 任务不存在或结果已过期
+var ErrJobNotFound = errors.New("submission not found")
+
+// ErrJobFinished 任务已经执行完成，无法取消
+var ErrJobFinished = errors.New("submission has already finished")
+
+// Job 表示一个异步执行的提交
+type Job struct {
+	ID         string            `json:"id"`
+	Status     string            `json:"status"`
+	CreatedAt  time.Time         `json:"createdAt"`
+	StartedAt  *time.Time        `json:"startedAt,omitempty"`
+	FinishedAt *time.Time        `json:"finishedAt,omitempty"`
+	Result     *sandbox.Response `json:"result,omitempty"`
+
+	request sandbox.Request
+	ctx     context.Context
+	cancel  context.CancelFunc
+	done    chan struct{} // 任务完成（包括被取消）后关闭
+}
+
+// QueueStats 队列状态统计
+type QueueStats struct {
+	Queued   int `json:"queued"`   // 等待中的任务数
+	Running  int `json:"running"`  // 正在执行的任务数
+	Workers  int `json:"workers"`  // 工作协程数
+	Capacity int `json:"capacity"` // 队列容量
+}
+
+// SubmissionQueue 有界的提交队列和固定大小的工作池
+type SubmissionQueue struct {
+	api      *sandbox.SandboxAPI
+	workers  int
+	capacity int
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	ready   *sync.Cond // 有任务入队或队列关闭时通知工作协程
+	pending []*Job     // 等待执行的任务（先进先出），排队时被取消的任务立即移除
+	jobs    map[string]*Job
+	running int
+	closed  bool
+
+	wg   sync.WaitGroup
+	stop chan struct{}
+}
+
+// NewSubmissionQueue 创建队列并启动工作协程
+// workers: 并发执行的任务数; capacity: 最多排队的任务数; ttl: 完成的任务结果保留时长
+func NewSubmissionQueue(api *sandbox.SandboxAPI, workers, capacity int, ttl time.Duration) *SubmissionQueue {
+	if workers <= 0 {
+		workers = 1
+	}
+	if capacity < 0 {
+		capacity = 0
+	}
+	q := &SubmissionQueue{
+		api:      api,
+		workers:  workers,
+		capacity: capacity,
+		ttl:      ttl,
+		jobs:     make(map[string]*Job),
+		stop:     make(chan struct{}),
+	}
+	q.ready = sync.NewCond(&q.mu)
+
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go q.worker()
+	}
+	q.wg.Add(1)
+	go q.janitor()
+
+	log.Printf("提交队列已启动: %d 个工作协程, 队列容量 %d, 结果保留 %v", workers, capacity, ttl)
+	return q
+}
+
+// Submit 将请求加入队列，队列满时返回 ErrQueueFull
+func (q *SubmissionQueue) Submit(req sandbox.Request) (*Job, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &Job{
+		ID:        uuid.New().String(),
+		Status:    JobQueued,
+		CreatedAt: time.Now(),
+		request:   req,
+		ctx:       ctx,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return nil, ErrQueueClosed
+	}
+	// 空闲的工作协程会立即取走任务，不占用队列容量
+	if len(q.pending) >= q.capacity+q.workers-q.running {
+		cancel()
+		return nil, ErrQueueFull
+	}
+	q.pending = append(q.pending, job)
+	q.jobs[job.ID] = job
+	q.ready.Signal()
+	return job.snapshot(), nil
+}
+
+// Cancel 取消任务：排队中的任务不再执行，正在执行的任务终止编译或用户程序。
+// 返回的 done 在任务结果可用后关闭，已完成的任务返回 ErrJobFinished
+func (q *SubmissionQueue) Cancel(id string) (<-chan struct{}, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	switch job.Status {
+	case JobFinished:
+		return job.done, ErrJobFinished
+	case JobQueued:
+		for i, queued := range q.pending {
+			if queued == job {
+				q.pending = append(q.pending[:i], q.pending[i+1:]...)
+				break
+			}
+		}
+		q.finish(job, sandbox.Response{
+			Status:   string(sandbox.StatusCancelled),
+			ExitCode: -1,
+			Error:    sandbox.ErrCancelled.Error(),
+		})
+	}
+	job.cancel()
+	log.Printf("提交 %s 已取消", id)
+	return job.done, nil
+}
+
+// Get 返回任务的当前状态快照
+func (q *SubmissionQueue) Get(id string) (*Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[id]
+	if !ok {
+		return nil, false
+	}
+	return job.snapshot(), true
+}
+
+// Stats 返回队列统计信息
+func (q *SubmissionQueue) Stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return QueueStats{
+		Queued:   len(q.pending),
+		Running:  q.running,
+		Workers:  q.workers,
+		Capacity: q.capacity,
+	}
+}
+
+// Close 停止接收新任务，等待已排队和正在执行的任务完成
+func (q *SubmissionQueue) Close() {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.closed = true
+	q.ready.Broadcast()
+	close(q.stop)
+	q.mu.Unlock()
+
+	q.wg.Wait()
+	log.Println("提交队列已关闭")
+}
+
+// worker 从队列取出任务并执行，队列关闭且任务全部取出后退出
+func (q *SubmissionQueue) worker() {
+	defer q.wg.Done()
+	for {
+		q.mu.Lock()
+		for len(q.pending) == 0 && !q.closed {
+			q.ready.Wait()
+		}
+		if len(q.pending) == 0 {
+			q.mu.Unlock()
+			return
+		}
+		job := q.pending[0]
+		q.pending[0] = nil
+		q.pending = q.pending[1:]
+
+		now := time.Now()
+		job.Status = JobRunning
+		job.StartedAt = &now
+		q.running++
+		q.mu.Unlock()
+
+		response := q.api.ExecuteContext(job.ctx, job.request)
+		job.cancel()
+
+		q.mu.Lock()
+		q.finish(job, response)
+		finished := *job.FinishedAt
+		q.running--
+		q.mu.Unlock()
+
+		log.Printf("提交 %s 执行完成: %s (等待 %v, 执行 %v)",
+			job.ID, response.Status, now.Sub(job.CreatedAt), finished.Sub(now))
+	}
+}
+
+// janitor 定期清理已过期的完成任务
+func (q *SubmissionQueue) janitor() {
+	defer q.wg.Done()
+	if q.ttl <= 0 {
+		return
+	}
+	ticker := time.NewTicker(q.ttl / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			q.mu.Lock()
+			for id, job := range q.jobs {
+				if job.Status == JobFinished && time.Since(*job.FinishedAt) > q.ttl {
+					delete(q.jobs, id)
+				}
+			}
+			q.mu.Unlock()
+		case <-q.stop:
+			return
+		}
+	}
+}
+
+// finish 记录任务结果，调用方需持有队列锁
+func (q *SubmissionQueue) finish(job *Job, response sandbox.Response) {
+	finished := time.Now()
+	job.Status = JobFinished
+	job.FinishedAt = &finished
+	job.Result = &response
+	close(job.done)
+}
+
+// snapshot 返回任务的副本，调用方需持有队列锁
+func (j *Job) snapshot() *Job {
+	cp := *j
+	return &cp
+}
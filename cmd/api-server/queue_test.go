@@ -0,0 +1,119 @@
+// cmd/api-server/queue_test.go
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/CodeRushOJ/croj-sandbox/internal/sandbox"
+)
+
+// idleQueue returns a queue without worker goroutines, so submitted jobs stay queued and the
+// test controls how many jobs count as running.
+func idleQueue(workers, capacity, running int) *SubmissionQueue {
+	q := &SubmissionQueue{
+		workers:  workers,
+		capacity: capacity,
+		running:  running,
+		jobs:     make(map[string]*Job),
+		stop:     make(chan struct{}),
+	}
+	q.ready = sync.NewCond(&q.mu)
+	return q
+}
+
+func TestSubmissionQueueAdmission(t *testing.T) {
+	tests := []struct {
+		name                       string
+		workers, capacity, running int
+		want                       int // 被接受的提交数
+	}{
+		{name: "idle workers take jobs beyond capacity", workers: 2, capacity: 3, want: 5},
+		{name: "busy workers", workers: 2, capacity: 3, running: 2, want: 3},
+		{name: "one busy worker", workers: 2, capacity: 3, running: 1, want: 4},
+		{name: "no queue", workers: 1, capacity: 0, want: 1},
+		{name: "no queue and busy", workers: 1, capacity: 0, running: 1, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := idleQueue(tt.workers, tt.capacity, tt.running)
+			admitted := 0
+			for i := 0; i < tt.want+2; i++ {
+				_, err := q.Submit(sandbox.Request{})
+				if errors.Is(err, ErrQueueFull) {
+					break
+				}
+				if err != nil {
+					t.Fatalf("submit: %v", err)
+				}
+				admitted++
+			}
+			if admitted != tt.want {
+				t.Errorf("admitted %d submissions, want %d", admitted, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubmissionQueueCancel(t *testing.T) {
+	q := idleQueue(1, 1, 0)
+	first, err := q.Submit(sandbox.Request{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := q.Submit(sandbox.Request{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Submit(sandbox.Request{}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("submit to a full queue: %v, want ErrQueueFull", err)
+	}
+
+	// 取消排队中的任务：立即完成并让出队列位置
+	done, err := q.Cancel(first.ID)
+	if err != nil {
+		t.Fatalf("cancel queued job: %v", err)
+	}
+	select {
+	case <-done:
+	default:
+		t.Error("cancelled queued job is not done")
+	}
+	job, _ := q.Get(first.ID)
+	if job.Status != JobFinished || job.Result == nil || job.Result.Status != string(sandbox.StatusCancelled) {
+		t.Errorf("cancelled queued job: %s, %+v", job.Status, job.Result)
+	}
+	if stats := q.Stats(); stats.Queued != 1 {
+		t.Errorf("%d jobs queued after cancelling one of two", stats.Queued)
+	}
+	if _, err := q.Submit(sandbox.Request{}); err != nil {
+		t.Errorf("submit after cancelling a queued job: %v", err)
+	}
+
+	// 再次取消已完成的任务
+	if _, err := q.Cancel(first.ID); !errors.Is(err, ErrJobFinished) {
+		t.Errorf("cancel finished job: %v, want ErrJobFinished", err)
+	}
+	if _, err := q.Cancel("missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("cancel unknown job: %v, want ErrJobNotFound", err)
+	}
+
+	// 取消正在执行的任务：终止执行，结果由工作协程写入
+	q.mu.Lock()
+	running := q.jobs[second.ID]
+	running.Status = JobRunning
+	q.mu.Unlock()
+	done, err = q.Cancel(second.ID)
+	if err != nil {
+		t.Fatalf("cancel running job: %v", err)
+	}
+	if running.ctx.Err() == nil {
+		t.Error("context of the cancelled running job is still active")
+	}
+	select {
+	case <-done:
+		t.Error("running job is done before its worker finished it")
+	default:
+	}
+}
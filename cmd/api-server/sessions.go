@@ -0,0 +1,336 @@
+// cmd/api-server/sessions.go
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/CodeRushOJ/croj-sandbox/internal/sandbox"
+	"github.com/google/uuid"
+)
+
+// ErrTooManySessions 同时存在的会话数已达上限
+var ErrTooManySessions = errors.New("too many playground sessions")
+
+// ErrSessionFinished 会话的程序已经结束，不能再写入输入
+var ErrSessionFinished = errors.New("session has finished")
+
+// ErrSessionsBusy 运行中的会话程序已占满会话可用的执行槽位
+var ErrSessionsBusy = errors.New("too many running playground sessions")
+
+// ErrStdinClosed 会话的标准输入已经关闭（已发送EOF）
+var ErrStdinClosed = errors.New("session stdin is closed")
+
+// ErrInputQueueFull 程序尚未读取的输入超过上限
+var ErrInputQueueFull = errors.New("session input queue is full")
+
+const (
+	// sessionInputChunks 每个会话排队等待程序读取的输入块数上限
+	sessionInputChunks = 64
+	// sessionInputBytes 每个会话排队等待程序读取的输入字节数上限
+	sessionInputBytes = 1 << 20
+)
+
+// Session 一次交互式运行：程序在限制内保持运行，标准输入可以分多次写入，输出通过事件流读取
+type Session struct {
+	ID string
+
+	stdin  *io.PipeWriter
+	input  chan string // 等待写入标准输入的数据，由 feedStdin 写入管道，关闭表示EOF
+	output *outputQueue
+	cancel context.CancelFunc
+	done   chan struct{} // 程序结束后关闭
+
+	mu         sync.Mutex
+	inputBytes int  // input 中排队的字节数
+	inputEOF   bool // input 已关闭
+	result     *sandbox.Response
+	lastActive time.Time
+	watchers   int // 正在读取事件流的连接数，有连接时不按空闲回收
+}
+
+// touch 记录会话活动时间
+func (s *Session) touch() {
+	s.mu.Lock()
+	s.lastActive = time.Now()
+	s.mu.Unlock()
+}
+
+// Write 将数据排入程序的标准输入，eof 为 true 时随后关闭标准输入。
+// 不等待程序读取：排队的输入超过上限时返回 ErrInputQueueFull
+func (s *Session) Write(data string, eof bool) error {
+	select {
+	case <-s.done:
+		return ErrSessionFinished
+	default:
+	}
+	s.touch()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.inputEOF {
+		return ErrStdinClosed
+	}
+	if data != "" {
+		if s.inputBytes+len(data) > sessionInputBytes {
+			return ErrInputQueueFull
+		}
+		select {
+		case s.input <- data:
+			s.inputBytes += len(data)
+		default:
+			return ErrInputQueueFull
+		}
+	}
+	if eof {
+		s.inputEOF = true
+		close(s.input)
+	}
+	return nil
+}
+
+// feedStdin 按顺序把排队的输入写入程序的标准输入，写入会阻塞到程序读取为止。
+// 输入关闭后关闭管道使程序读到EOF；程序结束时管道关闭，写入随之返回
+func (s *Session) feedStdin() {
+	for {
+		select {
+		case data, ok := <-s.input:
+			if !ok {
+				s.stdin.Close()
+				return
+			}
+			if _, err := io.WriteString(s.stdin, data); err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.inputBytes -= len(data)
+			s.mu.Unlock()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Result 返回程序结束后的结果，仍在运行时返回nil
+func (s *Session) Result() *sandbox.Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.result
+}
+
+// SessionManager 管理交互式会话，回收空闲超时的会话
+type SessionManager struct {
+	api         *sandbox.SandboxAPI
+	maxSessions int
+	running     chan struct{} // 运行中会话程序的槽位，为nil时不限制
+	idleTimeout time.Duration
+	wallLimit   time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+	closed   bool
+
+	wg   sync.WaitGroup
+	stop chan struct{}
+}
+
+// NewSessionManager 创建会话管理器
+// maxSessions: 同时存在的会话上限; maxRunning: 同时运行的会话程序上限，应等于沙箱的
+// MaxParallelSessions，超出时立即拒绝而不是等待会话槽位（0为不限制）;
+// idleTimeout: 没有输入也没有事件流连接多久后回收;
+// wallLimit: 会话程序的墙钟时间上限（CPU时间限制仍按请求生效）
+func NewSessionManager(api *sandbox.SandboxAPI, maxSessions, maxRunning int, idleTimeout, wallLimit time.Duration) *SessionManager {
+	m := &SessionManager{
+		api:         api,
+		maxSessions: maxSessions,
+		idleTimeout: idleTimeout,
+		wallLimit:   wallLimit,
+		sessions:    make(map[string]*Session),
+		stop:        make(chan struct{}),
+	}
+	if maxRunning > 0 {
+		m.running = make(chan struct{}, maxRunning)
+	}
+	if idleTimeout > 0 {
+		m.wg.Add(1)
+		go m.janitor()
+	}
+	return m
+}
+
+// Start 编译并启动请求中的程序，立即返回会话
+func (m *SessionManager) Start(req sandbox.Request) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, ErrQueueClosed
+	}
+	if m.maxSessions > 0 && len(m.sessions) >= m.maxSessions {
+		return nil, ErrTooManySessions
+	}
+	if m.running != nil {
+		select {
+		case m.running <- struct{}{}:
+		default:
+			return nil, ErrSessionsBusy
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stdinReader, stdinWriter := io.Pipe()
+	s := &Session{
+		ID:         uuid.New().String(),
+		stdin:      stdinWriter,
+		input:      make(chan string, sessionInputChunks),
+		output:     newOutputQueue(),
+		cancel:     cancel,
+		done:       make(chan struct{}),
+		lastActive: time.Now(),
+	}
+	m.sessions[s.ID] = s
+	go s.feedStdin()
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		if m.running != nil {
+			defer func() { <-m.running }()
+		}
+		response := m.api.ExecuteSession(ctx, req, sandbox.SessionOptions{
+			Stdin:     stdinReader,
+			Sink:      s.output.push,
+			WallLimit: m.wallLimit,
+		})
+		// 结束 feedStdin 中阻塞的写入和沙箱的输入拷贝协程
+		stdinReader.CloseWithError(ErrSessionFinished)
+		cancel()
+		s.mu.Lock()
+		s.result = &response
+		s.lastActive = time.Now()
+		s.mu.Unlock()
+		close(s.done)
+		log.Printf("会话 %s 结束: %s", s.ID, response.Status)
+	}()
+	return s, nil
+}
+
+// Get 返回会话
+func (m *SessionManager) Get(id string) (*Session, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[id]
+	return s, ok
+}
+
+// Remove 终止会话的程序并删除会话
+func (m *SessionManager) Remove(id string) bool {
+	m.mu.Lock()
+	s, ok := m.sessions[id]
+	delete(m.sessions, id)
+	m.mu.Unlock()
+	if ok {
+		s.cancel()
+	}
+	return ok
+}
+
+// Close 终止所有会话并等待程序退出
+func (m *SessionManager) Close() {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return
+	}
+	m.closed = true
+	close(m.stop)
+	for id, s := range m.sessions {
+		s.cancel()
+		delete(m.sessions, id)
+	}
+	m.mu.Unlock()
+	m.wg.Wait()
+}
+
+// janitor 定期回收空闲超时的会话：运行中的程序被终止，已结束的会话被删除
+func (m *SessionManager) janitor() {
+	defer m.wg.Done()
+	ticker := time.NewTicker(m.idleTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.mu.Lock()
+			for id, s := range m.sessions {
+				s.mu.Lock()
+				idle := s.watchers == 0 && time.Since(s.lastActive) > m.idleTimeout
+				s.mu.Unlock()
+				if idle {
+					s.cancel()
+					delete(m.sessions, id)
+					log.Printf("会话 %s 空闲超时，已回收", id)
+				}
+			}
+			m.mu.Unlock()
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// serveSessionEvents 以Server-Sent Events发送会话的输出，程序结束后发送 result 事件。
+// 同一会话同时只允许一个事件流连接，否则输出会被多个连接瓜分
+func serveSessionEvents(w http.ResponseWriter, r *http.Request, s *Session) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "不支持流式响应", http.StatusInternalServerError)
+		return
+	}
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("取消流式响应写超时失败: %v", err)
+	}
+	s.mu.Lock()
+	if s.watchers > 0 {
+		s.mu.Unlock()
+		http.Error(w, "会话已有事件流连接", http.StatusConflict)
+		return
+	}
+	s.watchers++
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.watchers--
+		s.lastActive = time.Now()
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	send := func() {
+		for _, chunk := range s.output.drain() {
+			writeEvent(w, chunk.Stream, chunk)
+		}
+		flusher.Flush()
+	}
+	for {
+		select {
+		case <-s.output.notify:
+			send()
+		case <-s.done:
+			send()
+			writeEvent(w, "result", s.Result())
+			flusher.Flush()
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// cmd/api-server/sessions_test.go
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSessionEventsRejectsSecondWatcher(t *testing.T) {
+	s := &Session{output: newOutputQueue(), done: make(chan struct{})}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serveSessionEvents(w, r, s)
+	}))
+	defer server.Close()
+
+	first, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Body.Close()
+	if first.StatusCode != http.StatusOK {
+		t.Fatalf("first watcher: status %d", first.StatusCode)
+	}
+
+	second, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second.Body.Close()
+	if second.StatusCode != http.StatusConflict {
+		t.Errorf("second watcher: status %d, want %d", second.StatusCode, http.StatusConflict)
+	}
+
+	// 第一个连接断开后可以重新连接
+	first.Body.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s.mu.Lock()
+		watchers := s.watchers
+		s.mu.Unlock()
+		if watchers == 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(s.done)
+	again, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again.Body.Close()
+	if again.StatusCode != http.StatusOK {
+		t.Errorf("reconnect after the first watcher left: status %d", again.StatusCode)
+	}
+}
@@ -0,0 +1,100 @@
+// cmd/api-server/stream.go
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/CodeRushOJ/croj-sandbox/internal/sandbox"
+)
+
+// outputQueue 缓存待发送的输出块。沙箱的输出拷贝协程只负责入队，不会被慢速客户端阻塞；
+// 输出总量受 MaxStdoutSize / MaxStderrSize 限制，因此队列不会无限增长
+type outputQueue struct {
+	mu     sync.Mutex
+	chunks []sandbox.OutputChunk
+	notify chan struct{}
+}
+
+func newOutputQueue() *outputQueue {
+	return &outputQueue{notify: make(chan struct{}, 1)}
+}
+
+// push 是传给沙箱的 OutputSink
+func (q *outputQueue) push(chunk sandbox.OutputChunk) {
+	q.mu.Lock()
+	q.chunks = append(q.chunks, chunk)
+	q.mu.Unlock()
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// drain 取出所有已排队的输出块
+func (q *outputQueue) drain() []sandbox.OutputChunk {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	chunks := q.chunks
+	q.chunks = nil
+	return chunks
+}
+
+// serveStream 以Server-Sent Events执行请求：编译输出、标准输出和标准错误分别作为
+// compile / stdout / stderr 事件实时发送，最后发送包含完整结果的 result 事件。
+// 客户端断开连接时取消执行
+func serveStream(w http.ResponseWriter, r *http.Request, api *sandbox.SandboxAPI, req sandbox.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "不支持流式响应", http.StatusInternalServerError)
+		return
+	}
+	// 执行时间可能超过服务器的写超时
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("取消流式响应写超时失败: %v", err)
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	queue := newOutputQueue()
+	done := make(chan sandbox.Response, 1)
+	go func() {
+		done <- api.ExecuteStream(r.Context(), req, queue.push)
+	}()
+
+	send := func() {
+		for _, chunk := range queue.drain() {
+			writeEvent(w, chunk.Stream, chunk)
+		}
+		flusher.Flush()
+	}
+	for {
+		select {
+		case <-queue.notify:
+			send()
+		case response := <-done:
+			send()
+			writeEvent(w, "result", response)
+			flusher.Flush()
+			return
+		}
+	}
+}
+
+// writeEvent 写入一个SSE事件，数据为JSON；客户端断开后的写入错误被忽略，执行会随请求Context取消
+func writeEvent(w http.ResponseWriter, event string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("编码 %s 事件失败: %v", event, err)
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
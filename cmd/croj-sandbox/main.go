@@ -14,9 +14,9 @@ import (
 )
 
 var (
-	language = flag.String("lang", "go", "编程语言 (go, cpp, python, java, javascript)")
+	language  = flag.String("lang", "go", "编程语言 (go, cpp, python, java, javascript)")
 	timeLimit = flag.Int("time", 3, "执行时间限制（秒）")
-	memLimit = flag.Int("mem", 512, "内存限制（MB）")
+	memLimit  = flag.Int("mem", 512, "内存限制（MB）")
 )
 
 func main() {
@@ -27,7 +27,7 @@ func main() {
 	// --- 配置 ---
 	cfg := sandbox.DefaultConfig()
 	cfg.DefaultExecuteTimeLimit = time.Duration(*timeLimit) * time.Second
-	
+
 	// 确保选择的语言受支持
 	if _, ok := cfg.Languages[*language]; !ok {
 		log.Fatalf("不支持的语言: %s", *language)
@@ -42,7 +42,7 @@ func main() {
 
 	// --- 测试用例 ---
 	testCases := getTestCases()
-	
+
 	// 获取特定语言的测试用例
 	langTests, ok := testCases[*language]
 	if !ok {
@@ -52,8 +52,8 @@ func main() {
 	// --- 运行测试用例 ---
 	for name, tc := range langTests {
 		fmt.Printf("\n--- 运行测试用例: [%s - %s] ---\n", *language, name)
-		ctx, cancel := context.WithTimeout(context.Background(), 
-			cfg.DefaultCompileTimeLimit + cfg.DefaultExecuteTimeLimit + 5*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(),
+			cfg.DefaultCompileTimeLimit+cfg.DefaultExecuteTimeLimit+5*time.Second)
 
 		// 显示是否有预期输出
 		if tc.expectedOutput != nil {
@@ -65,12 +65,12 @@ func main() {
 		// --- 打印结果 ---
 		fmt.Printf("状态: %s\n", result.Status)
 		fmt.Printf("退出码: %d\n", result.ExitCode)
-		fmt.Printf("用时: %d 毫秒\n", result.TimeUsedMillis)
+		fmt.Printf("用时: %d 毫秒 (CPU: %d 毫秒)\n", result.TimeUsedMillis, result.CPUTimeMillis)
 		fmt.Printf("内存: %d KB (未测量)\n", result.MemoryUsedKB)
 
 		if result.Status == sandbox.StatusCompileError {
 			fmt.Printf("编译输出:\n%s\n", result.CompileOutput)
-			if result.Error != "" && result.Error != result.CompileOutput { 
+			if result.Error != "" && result.Error != result.CompileOutput {
 				fmt.Printf("编译错误详情: %s\n", result.Error)
 			}
 		} else {
@@ -88,7 +88,7 @@ func main() {
 				} else {
 					fmt.Println("标准错误输出: (空)")
 				}
-				
+
 				if result.Stdout != "" {
 					maxDisplay := 256
 					stdoutDisplay := result.Stdout
@@ -120,7 +120,7 @@ type TestCase struct {
 // getTestCases 返回所有支持语言的测试用例
 func getTestCases() map[string]map[string]TestCase {
 	allTests := make(map[string]map[string]TestCase)
-	
+
 	// Go 语言测试用例
 	allTests["go"] = map[string]TestCase{
 		"简单输出": {
@@ -128,7 +128,7 @@ func getTestCases() map[string]map[string]TestCase {
 package main
 import "fmt"
 func main() { fmt.Println("Hello Go!") }`,
-			stdin: nil,
+			stdin:          nil,
 			expectedOutput: stringPtr("Hello Go!"),
 		},
 		"读取输入": {
@@ -139,11 +139,11 @@ func main() {
 	input, _ := io.ReadAll(os.Stdin)
 	fmt.Printf("收到: %s", string(input))
 }`,
-			stdin: stringPtr("Go 测试输入\n"),
+			stdin:          stringPtr("Go 测试输入\n"),
 			expectedOutput: nil,
 		},
 	}
-	
+
 	// C++ 测试用例
 	allTests["cpp"] = map[string]TestCase{
 		"简单输出": {
@@ -153,7 +153,7 @@ int main() {
 	std::cout << "Hello C++!" << std::endl;
 	return 0;
 }`,
-			stdin: nil,
+			stdin:          nil,
 			expectedOutput: stringPtr("Hello C++!"),
 		},
 		"读取输入": {
@@ -166,28 +166,28 @@ int main() {
 	std::cout << "收到: " << input << std::endl;
 	return 0;
 }`,
-			stdin: stringPtr("C++ 测试输入"),
+			stdin:          stringPtr("C++ 测试输入"),
 			expectedOutput: nil,
 		},
 	}
-	
+
 	// Python 测试用例
 	allTests["python"] = map[string]TestCase{
 		"简单输出": {
 			code: `
 print("Hello Python!")`,
-			stdin: nil,
+			stdin:          nil,
 			expectedOutput: stringPtr("Hello Python!"),
 		},
 		"读取输入": {
 			code: `
 input_data = input()
 print(f"收到: {input_data}")`,
-			stdin: stringPtr("Python 测试输入"),
+			stdin:          stringPtr("Python 测试输入"),
 			expectedOutput: nil,
 		},
 	}
-	
+
 	// Java 测试用例
 	allTests["java"] = map[string]TestCase{
 		"简单输出": {
@@ -197,7 +197,7 @@ public class Main {
 		System.out.println("Hello Java!");
 	}
 }`,
-			stdin: nil,
+			stdin:          nil,
 			expectedOutput: stringPtr("Hello Java!"),
 		},
 		"读取输入": {
@@ -212,25 +212,25 @@ public class Main {
 		scanner.close();
 	}
 }`,
-			stdin: stringPtr("Java 测试输入"),
+			stdin:          stringPtr("Java 测试输入"),
 			expectedOutput: nil,
 		},
 	}
-	
+
 	// JavaScript 测试用例
 	allTests["javascript"] = map[string]TestCase{
 		"简单输出": {
 			code: `
 console.log("Hello JavaScript!");`,
-			stdin: nil,
+			stdin:          nil,
 			expectedOutput: stringPtr("Hello JavaScript!"),
 		},
 	}
-	
+
 	return allTests
 }
 
 // 辅助函数：获取字符串指针
 func stringPtr(s string) *string {
 	return &s
-}
\ No newline at end of file
+}
@@ -49,7 +49,7 @@ func inferLanguage(filename string) string {
 
 func main() {
 	flag.Parse()
-	
+
 	// 初始化调试模式
 	if *debug || *verbose {
 		util.DebugMode = true
@@ -58,22 +58,22 @@ func main() {
 		// 也检查环境变量
 		util.InitDebugMode()
 	}
-	
+
 	// 设置日志格式
 	log.SetFlags(log.Ldate | log.Ltime)
-	
+
 	// 验证参数
 	if *sourceFile == "" {
 		flag.Usage()
 		log.Fatal("必须指定源代码文件路径")
 	}
-	
+
 	// 读取源代码
 	sourceCode, err := os.ReadFile(*sourceFile)
 	if err != nil {
 		log.Fatalf("无法读取源代码文件: %v", err)
 	}
-	
+
 	// 确定编程语言
 	lang := *language
 	if lang == "" {
@@ -83,7 +83,7 @@ func main() {
 		}
 		fmt.Printf("从文件扩展名推断语言: %s\n", lang)
 	}
-	
+
 	// 读取标准输入（如果提供）
 	var stdin *string
 	if *stdinFile != "" {
@@ -95,7 +95,7 @@ func main() {
 		stdinStr := string(stdinData)
 		stdin = &stdinStr
 	}
-	
+
 	// 读取期望输出（如果提供）
 	var expectedOutput *string
 	if *outputFile != "" {
@@ -107,7 +107,7 @@ func main() {
 		outputStr := string(outputData)
 		expectedOutput = &outputStr
 	}
-	
+
 	// 创建执行请求
 	request := sandbox.Request{
 		Language:       lang,
@@ -117,9 +117,9 @@ func main() {
 		MemoryLimit:    memLimit,
 		ExpectedOutput: expectedOutput,
 	}
-	
+
 	var response sandbox.Response
-	
+
 	// 检查是使用本地执行还是远程API执行
 	if *apiURL != "" {
 		// 远程API执行
@@ -128,7 +128,7 @@ func main() {
 		// 本地执行
 		response = executeLocal(request)
 	}
-	
+
 	// 如果指定了JSON输出模式，则直接输出JSON
 	if *jsonOutput {
 		prettyJSON, err := json.MarshalIndent(response, "", "  ")
@@ -138,27 +138,27 @@ func main() {
 		fmt.Println(string(prettyJSON))
 		return
 	}
-	
+
 	// 打印结果
 	fmt.Printf("\n=== 执行结果 ===\n")
 	fmt.Printf("状态: %s\n", response.Status)
 	fmt.Printf("退出码: %d\n", response.ExitCode)
-	fmt.Printf("执行时间: %d ms\n", response.TimeUsed)
-	
+	fmt.Printf("执行时间: %d ms (CPU: %d ms)\n", response.TimeUsed, response.CPUTimeUsed)
+
 	// 显示内存使用信息
 	if response.MemoryUsed > 0 {
 		fmt.Printf("内存使用: %d KB (限制: %d MB)\n", response.MemoryUsed, *memLimit)
 	} else {
 		fmt.Printf("内存使用: 未测量 (限制: %d MB)\n", *memLimit)
 	}
-	
+
 	// 检查是否是Wrong Answer
 	if response.Status == string(sandbox.StatusWrongAnswer) {
 		fmt.Printf("\n=== 输出比较 ===\n")
 		fmt.Printf("预期输出:\n%s\n", *expectedOutput)
 		fmt.Printf("实际输出:\n%s\n", response.Stdout)
 		fmt.Printf("\n输出不匹配! 请检查以上内容的差异。\n")
-		
+
 		// 如果在详细模式下，显示规范化后的字符串比较
 		if *verbose {
 			normalizedExpected := util.NormalizeString(*expectedOutput)
@@ -167,23 +167,23 @@ func main() {
 			fmt.Printf("规范化后的实际输出:\n%s\n", normalizedActual)
 		}
 	}
-	
+
 	if response.CompileError != "" {
 		fmt.Printf("\n=== 编译错误 ===\n%s\n", response.CompileError)
 	}
-	
+
 	if response.Stdout != "" && response.Status != string(sandbox.StatusWrongAnswer) {
 		fmt.Printf("\n=== 标准输出 ===\n%s\n", response.Stdout)
 	}
-	
+
 	if response.Stderr != "" {
 		fmt.Printf("\n=== 标准错误 ===\n%s\n", response.Stderr)
 	}
-	
+
 	if response.Error != "" && response.Error != response.CompileError {
 		fmt.Printf("\n=== 错误信息 ===\n%s\n", response.Error)
 	}
-	
+
 	// 在详细模式下，打印JSON格式的完整结果
 	if *verbose {
 		prettyJSON, _ := json.MarshalIndent(response, "", "  ")
@@ -199,7 +199,7 @@ func executeLocal(req sandbox.Request) sandbox.Response {
 		log.Fatalf("初始化本地沙箱失败: %v", err)
 	}
 	defer api.Close()
-	
+
 	fmt.Printf("使用本地沙箱执行 %s 代码...\n", req.Language)
 	return api.Execute(req)
 }
@@ -210,28 +210,28 @@ func executeRemote(req sandbox.Request, apiURL string) sandbox.Response {
 	if err != nil {
 		log.Fatalf("序列化请求失败: %v", err)
 	}
-	
+
 	fmt.Printf("向远程API发送 %s 代码执行请求: %s\n", req.Language, apiURL)
 	resp, err := http.Post(apiURL, "application/json", bytes.NewBuffer(reqJSON))
 	if err != nil {
 		log.Fatalf("请求API失败: %v", err)
 	}
 	defer resp.Body.Close()
-	
+
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		log.Fatalf("API返回错误状态码 %d: %s", resp.StatusCode, string(body))
 	}
-	
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		log.Fatalf("读取API响应失败: %v", err)
 	}
-	
+
 	var response sandbox.Response
 	if err := json.Unmarshal(body, &response); err != nil {
 		log.Fatalf("解析API响应失败: %v", err)
 	}
-	
+
 	return response
 }
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"time"
 )
@@ -14,21 +15,102 @@ type Request struct {
 	SourceCode     string  `json:"sourceCode"`     // Source code to execute
 	Language       string  `json:"language"`       // Programming language (default: "go")
 	Stdin          *string `json:"stdin"`          // Optional standard input
-	Timeout        *int    `json:"timeout"`        // Optional custom timeout in seconds
+	Timeout        *int    `json:"timeout"`        // Optional custom CPU time limit in seconds
+	WallTimeout    *int    `json:"wallTimeout"`    // Optional custom wall-clock limit in seconds (default: 3x timeout)
 	MemoryLimit    *int    `json:"memoryLimit"`    // Optional memory limit in MB
+	StackLimit     *int    `json:"stackLimit"`     // Optional stack size limit in MB (-1 = unlimited)
+	FileSizeLimit  *int    `json:"fileSizeLimit"`  // Optional max size of a file written by the program in MB
+	OpenFilesLimit *int    `json:"openFilesLimit"` // Optional max number of open file descriptors
+	ProcessLimit   *int    `json:"processLimit"`   // Optional max number of processes/threads
 	ExpectedOutput *string `json:"expectedOutput"` // Optional expected output for comparison
+
+	// Optional multi-file submission: extra files placed in the run dir before compiling (relative
+	// path -> content) and/or a base64-encoded tar, tar.gz or zip archive. When SourceCode is empty
+	// the language's main source file (e.g. Main.java) must be one of the files.
+	Files   map[string]string `json:"files,omitempty"`
+	Archive []byte            `json:"archive,omitempty"`
+
+	// Optional trusted problem files (e.g. grader.cpp + solution.h) linked with the user's code;
+	// they are not returned in the response
+	Grader *GraderSpec `json:"grader,omitempty"`
+
+	// Optional test cases; when present the source is compiled once and run against each case,
+	// and Stdin/ExpectedOutput are ignored.
+	TestCases []TestCase `json:"testCases,omitempty"`
+
+	// Optional IOI-style subtasks over TestCases; when present the score is the sum of subtask scores
+	Subtasks []Subtask `json:"subtasks,omitempty"`
+
+	// Optional run policy for TestCases: "run-all" (default), "stop-on-failure" or "samples-first";
+	// cases that are not run are reported as Skipped
+	RunPolicy string `json:"runPolicy,omitempty"`
+
+	// Optional file I/O: files written to the work dir before the run (name -> content), files collected
+	// afterwards, and expected contents of output files compared with the checker (name -> content)
+	InputFiles          map[string]string `json:"inputFiles,omitempty"`
+	OutputFiles         []string          `json:"outputFiles,omitempty"`
+	ExpectedOutputFiles map[string]string `json:"expectedOutputFiles,omitempty"`
+
+	// Optional output checker (default: exact comparison after whitespace normalization)
+	Checker *CheckerSpec `json:"checker,omitempty"`
+
+	// Optional interactor for interactive problems; when set, Stdin is passed to the interactor
+	// as its input file and the interactor decides the verdict
+	Interactor *InteractorSpec `json:"interactor,omitempty"`
 }
 
 // Response represents the execution result
 type Response struct {
-	Status       string `json:"status"`       // Execution status (e.g., "Accepted", "Runtime Error")
-	ExitCode     int    `json:"exitCode"`     // Process exit code
-	Stdout       string `json:"stdout"`       // Standard output content
-	Stderr       string `json:"stderr"`       // Standard error content
-	Error        string `json:"error"`        // Error message if any
-	TimeUsed     int64  `json:"timeUsed"`     // Execution time in milliseconds
-	MemoryUsed   int64  `json:"memoryUsed"`   // Memory usage in KB
-	CompileError string `json:"compileError"` // Compilation error if any
+	Status       string            `json:"status"`                // Execution status (e.g., "Accepted", "Runtime Error")
+	ExitCode     int               `json:"exitCode"`              // Process exit code
+	Signal       string            `json:"signal,omitempty"`      // Terminating signal, e.g. "SIGSEGV"
+	Stdout       string            `json:"stdout"`                // Standard output content
+	Stderr       string            `json:"stderr"`                // Standard error content
+	OutputFiles  map[string]string `json:"outputFiles,omitempty"` // Collected output files (file I/O)
+	Error        string            `json:"error"`                 // Error message if any
+	TimeUsed     int64             `json:"timeUsed"`              // Wall-clock execution time in milliseconds
+	CPUTimeUsed  int64             `json:"cpuTimeUsed"`           // CPU (user+sys) time in milliseconds, the basis for TLE
+	MemoryUsed   int64             `json:"memoryUsed"`            // Memory usage in KB
+	CompileError string            `json:"compileError"`          // Compilation error if any
+	CacheHit     bool              `json:"cacheHit"`              // Whether compilation was skipped via the artifact cache
+
+	CheckerMessage string `json:"checkerMessage,omitempty"` // Message from the output checker or interactor
+	Transcript     string `json:"transcript,omitempty"`     // Interaction transcript, if recorded
+
+	// Multi-testcase results (only set when the request carried TestCases)
+	Score           int                `json:"score,omitempty"`           // Score earned over all cases
+	TotalScore      int                `json:"totalScore,omitempty"`      // Maximum attainable score
+	TestCaseResults []TestCaseResponse `json:"testCaseResults,omitempty"` // Per-case results, in request order
+	Subtasks        []SubtaskResponse  `json:"subtasks,omitempty"`        // Per-subtask score breakdown
+}
+
+// SubtaskResponse represents the score of a single subtask
+type SubtaskResponse struct {
+	Name       string `json:"name"`       // Subtask name
+	Status     string `json:"status"`     // Accepted, Skipped (a dependency failed) or the first failed case's status
+	Score      int    `json:"score"`      // Score earned by this subtask
+	TotalScore int    `json:"totalScore"` // Maximum attainable score
+	Cases      []int  `json:"cases"`      // 0-based indexes of the subtask's test cases
+}
+
+// TestCaseResponse represents the result of a single test case
+type TestCaseResponse struct {
+	Status       string            `json:"status"`                 // Execution status of this case
+	ExitCode     int               `json:"exitCode"`               // Process exit code
+	Signal       string            `json:"signal,omitempty"`       // Terminating signal, e.g. "SIGSEGV"
+	Stdout       string            `json:"stdout"`                 // Standard output content
+	Stderr       string            `json:"stderr"`                 // Standard error content
+	OutputFiles  map[string]string `json:"outputFiles,omitempty"`  // Collected output files (file I/O)
+	Error        string            `json:"error"`                  // Error message if any
+	TimeUsed     int64             `json:"timeUsed"`               // Wall-clock execution time in milliseconds
+	CPUTimeUsed  int64             `json:"cpuTimeUsed"`            // CPU (user+sys) time in milliseconds
+	MemoryUsed   int64             `json:"memoryUsed"`             // Memory usage in KB
+	Score        int               `json:"score"`                  // Score earned by this case
+	TotalScore   int               `json:"totalScore"`             // Maximum attainable score of this case, also for skipped cases
+	PartialScore float64           `json:"partialScore,omitempty"` // Share (0..1) of the case awarded by the checker for a partially correct answer
+
+	CheckerMessage string `json:"checkerMessage,omitempty"` // Message from the output checker or interactor
+	Transcript     string `json:"transcript,omitempty"`     // Interaction transcript, if recorded
 }
 
 // SandboxAPI provides a simple API for the code execution sandbox
@@ -48,7 +130,7 @@ func NewSandboxAPIWithConfig(cfg Config) (*SandboxAPI, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize sandbox runner: %w", err)
 	}
-	
+
 	return &SandboxAPI{
 		runner: runner,
 		cfg:    cfg,
@@ -57,16 +139,46 @@ func NewSandboxAPIWithConfig(cfg Config) (*SandboxAPI, error) {
 
 // Execute runs the provided code and returns the result
 func (api *SandboxAPI) Execute(req Request) Response {
+	return api.ExecuteContext(context.Background(), req)
+}
+
+// ExecuteContext runs the provided code like Execute. Cancelling ctx stops compilation or kills
+// the running program's process tree, and the response then has status Cancelled.
+func (api *SandboxAPI) ExecuteContext(ctx context.Context, req Request) Response {
+	return api.ExecuteSession(ctx, req, SessionOptions{})
+}
+
+// ExecuteStream runs the provided code like Execute, passing compile output and the program's
+// stdout/stderr to sink while they are produced (sink may be nil). Cancelling ctx stops
+// compilation and kills the running program.
+func (api *SandboxAPI) ExecuteStream(ctx context.Context, req Request, sink OutputSink) Response {
+	return api.ExecuteSession(ctx, req, SessionOptions{Sink: sink})
+}
+
+// SessionOptions configures how a request's program is wired while it runs.
+type SessionOptions struct {
+	// Stdin, if set, is read while the program runs and replaces Request.Stdin, so input can be
+	// supplied incrementally (playground sessions). Closing it gives the program EOF.
+	Stdin io.Reader
+	// Sink receives compile output and the program's stdout/stderr while they are produced.
+	Sink OutputSink
+	// WallLimit replaces the request's wall-clock limit, e.g. to keep a program waiting for typed
+	// input alive longer than a judged run (0 = request's limit). The CPU time limit still applies.
+	WallLimit time.Duration
+}
+
+// ExecuteSession runs the provided code with the stream wiring in opts. Cancelling ctx stops
+// compilation and kills the running program.
+func (api *SandboxAPI) ExecuteSession(ctx context.Context, req Request, opts SessionOptions) Response {
 	// Set default language if not specified
 	language := req.Language
 	if language == "" {
 		language = "go" // 默认使用Go语言
 	}
-	
+
 	// Apply custom timeout if provided
-	ctx := context.Background()
 	var execTimeout time.Duration
-	
+
 	// 检查语言配置是否存在
 	if langConfig, ok := api.cfg.Languages[language]; ok {
 		execTimeout = langConfig.GetExecuteTimeout(api.cfg.DefaultExecuteTimeLimit)
@@ -74,89 +186,147 @@ func (api *SandboxAPI) Execute(req Request) Response {
 		// 回退到兼容字段
 		execTimeout = api.cfg.ExecTimeout
 	}
-	
+
 	// 应用自定义超时（如果提供）
 	var userSpecifiedTimeout bool = false
-	if req.Timeout != nil && *req.Timeout > 0 {
-		customTimeout := time.Duration(*req.Timeout) * time.Second
-		// 不超过合理限制
-		if customTimeout <= 30*time.Second {
-			execTimeout = customTimeout
-			userSpecifiedTimeout = true
-			log.Printf("API: 使用用户指定的超时: %.2f秒", execTimeout.Seconds())
-		}
-	}
-	
-	// 应用自定义内存限制（如果提供）
+	if customTimeout, ok := resolveTimeout(req.Timeout); ok {
+		execTimeout = customTimeout
+		userSpecifiedTimeout = true
+		log.Printf("API: 使用用户指定的超时: %.2f秒", execTimeout.Seconds())
+	}
+
+	// 应用自定义内存限制（如果提供，超出上限时被截断）
 	memoryLimit := api.cfg.DefaultExecuteMemoryLimit
-	if req.MemoryLimit != nil && *req.MemoryLimit > 0 {
-		// 转换 MB 到 bytes
-		customMemLimit := int64(*req.MemoryLimit) * 1024 * 1024
-		// 不超过合理上限（例如 4GB）
-		if customMemLimit <= 4*1024*1024*1024 {
-			memoryLimit = customMemLimit
-		} else {
-			log.Printf("请求的内存限制 %d MB 超出最大允许值，使用上限 4GB", *req.MemoryLimit)
-			memoryLimit = 4 * 1024 * 1024 * 1024
-		}
-	}
-
-	// 创建新的配置副本，而不是修改原始配置
-	customCfg := Config{
-		Languages:               api.cfg.Languages,
-		HostTempDir:             api.cfg.HostTempDir,
-		DefaultCompileTimeLimit: api.cfg.DefaultCompileTimeLimit,
-		DefaultExecuteTimeLimit: execTimeout, // 使用自定义超时
-		DefaultExecuteMemoryLimit: memoryLimit, // 使用自定义内存限制
-		CompileTimeout:          api.cfg.CompileTimeout,
-		ExecTimeout:             execTimeout, // 兼容性字段也更新
-		MaxStdoutSize:           api.cfg.MaxStdoutSize,
-		MaxStderrSize:           api.cfg.MaxStderrSize,
-		UserSpecifiedTimeout:    userSpecifiedTimeout, // 添加新字段标记用户是否指定了超时
-	}
-	
-	// Create context with timeout (估计编译时间+执行时间+额外缓冲)
-	compileTimeout := api.cfg.DefaultCompileTimeLimit
-	if api.cfg.CompileTimeout > 0 {
-		compileTimeout = api.cfg.CompileTimeout
-	}
-	ctx, cancel := context.WithTimeout(context.Background(), compileTimeout+execTimeout+5*time.Second)
-	defer cancel()
-	
+	if customMemLimit, ok := resolveMemoryLimit(req.MemoryLimit); ok {
+		memoryLimit = customMemLimit
+	}
+	wallTimeLimit := api.cfg.WallTimeLimit
+	if customWall, ok := resolveTimeout(req.WallTimeout); ok {
+		wallTimeLimit = customWall
+	}
+	if opts.WallLimit > 0 {
+		wallTimeLimit = opts.WallLimit
+	}
+
+	// 从服务端配置复制，只覆盖本次请求的限制和评测设置
+	customCfg := api.cfg
+	customCfg.DefaultExecuteTimeLimit = execTimeout   // 使用自定义超时
+	customCfg.DefaultExecuteMemoryLimit = memoryLimit // 使用自定义内存限制
+	customCfg.WallTimeLimit = wallTimeLimit
+	// 请求未设置的setrlimit限制沿用服务端配置
+	if stack := resolveStackLimit(req.StackLimit); stack != 0 {
+		customCfg.StackLimit = stack
+	}
+	if fileSize := resolveFileSizeLimit(req.FileSizeLimit); fileSize > 0 {
+		customCfg.FileSizeLimit = fileSize
+	}
+	if openFiles := resolveCount(req.OpenFilesLimit, MaxOpenFilesLimit); openFiles > 0 {
+		customCfg.OpenFilesLimit = openFiles
+	}
+	if processes := resolveCount(req.ProcessLimit, MaxProcessLimit); processes > 0 {
+		customCfg.ProcessLimit = processes
+	}
+	customCfg.ExecTimeout = execTimeout                   // 兼容性字段也更新
+	customCfg.UserSpecifiedTimeout = userSpecifiedTimeout // 标记用户是否指定了超时
+	customCfg.Checker = req.Checker
+	customCfg.Interactor = req.Interactor
+	customCfg.InputFiles = req.InputFiles
+	customCfg.OutputFiles = req.OutputFiles
+	customCfg.ExpectedOutputFiles = req.ExpectedOutputFiles
+	customCfg.SourceFiles = req.Files
+	customCfg.SourceArchive = req.Archive
+	customCfg.Grader = req.Grader
+	customCfg.Subtasks = req.Subtasks
+	customCfg.RunPolicy = req.RunPolicy
+	customCfg.OutputSink = opts.Sink
+	customCfg.StdinReader = opts.Stdin
+
 	// 运行代码（使用修改后的配置）
-	log.Printf("API: 调用RunWithConfig，用户指定超时: %v, 超时设置为: %.2f秒", 
+	log.Printf("API: 调用RunWithConfig，用户指定超时: %v, 超时设置为: %.2f秒",
 		customCfg.UserSpecifiedTimeout, customCfg.DefaultExecuteTimeLimit.Seconds())
-	result := api.runner.RunWithConfig(ctx, language, req.SourceCode, req.Stdin, req.ExpectedOutput, customCfg)
-	
-	// 转换为API响应
+	var result Result
+	if len(req.TestCases) > 0 {
+		log.Printf("API: 评测 %d 个测试用例", len(req.TestCases))
+		result = api.runner.RunTestCasesWithConfig(ctx, language, req.SourceCode, req.TestCases, customCfg)
+	} else {
+		result = api.runner.RunWithConfig(ctx, language, req.SourceCode, req.Stdin, req.ExpectedOutput, customCfg)
+	}
+
+	return newResponse(result)
+}
+
+// newResponse converts a runner Result into an API response
+func newResponse(result Result) Response {
 	response := Response{
-		Status:       string(result.Status),
-		ExitCode:     result.ExitCode,
-		Stdout:       result.Stdout,
-		Stderr:       result.Stderr,
-		Error:        result.Error,
-		TimeUsed:     result.TimeUsedMillis,
-		MemoryUsed:   result.MemoryUsedKB,
-		CompileError: result.CompileOutput,
-	}
-	
+		Status:         string(result.Status),
+		ExitCode:       result.ExitCode,
+		Signal:         result.Signal,
+		Stdout:         result.Stdout,
+		Stderr:         result.Stderr,
+		OutputFiles:    result.OutputFiles,
+		Error:          result.Error,
+		TimeUsed:       result.TimeUsedMillis,
+		CPUTimeUsed:    result.CPUTimeMillis,
+		MemoryUsed:     result.MemoryUsedKB,
+		CompileError:   result.CompileOutput,
+		CacheHit:       result.CacheHit,
+		CheckerMessage: result.CheckerMessage,
+		Transcript:     result.Transcript,
+		Score:          result.Score,
+		TotalScore:     result.TotalScore,
+	}
+
+	for _, caseRes := range result.TestCaseResults {
+		response.TestCaseResults = append(response.TestCaseResults, TestCaseResponse{
+			Status:         string(caseRes.Status),
+			ExitCode:       caseRes.ExitCode,
+			Signal:         caseRes.Signal,
+			Stdout:         caseRes.Stdout,
+			Stderr:         caseRes.Stderr,
+			OutputFiles:    caseRes.OutputFiles,
+			Error:          caseRes.Error,
+			TimeUsed:       caseRes.TimeUsedMillis,
+			CPUTimeUsed:    caseRes.CPUTimeMillis,
+			MemoryUsed:     caseRes.MemoryUsedKB,
+			Score:          caseRes.Score,
+			TotalScore:     caseRes.TotalScore,
+			PartialScore:   caseRes.PartialScore,
+			CheckerMessage: caseRes.CheckerMessage,
+			Transcript:     caseRes.Transcript,
+		})
+	}
+	for _, st := range result.SubtaskResults {
+		response.Subtasks = append(response.Subtasks, SubtaskResponse{
+			Name:       st.Name,
+			Status:     string(st.Status),
+			Score:      st.Score,
+			TotalScore: st.TotalScore,
+			Cases:      st.Cases,
+		})
+	}
+
 	return response
 }
 
+// Stats reports concurrency slot usage and wait-time metrics
+func (api *SandboxAPI) Stats() ConcurrencyStats {
+	return api.runner.Stats()
+}
+
 // ExecuteJSON accepts a JSON request string and returns a JSON response
 func (api *SandboxAPI) ExecuteJSON(jsonRequest string) (string, error) {
 	var req Request
 	if err := json.Unmarshal([]byte(jsonRequest), &req); err != nil {
 		return "", fmt.Errorf("failed to parse request JSON: %w", err)
 	}
-	
+
 	response := api.Execute(req)
-	
+
 	jsonResponse, err := json.Marshal(response)
 	if err != nil {
 		return "", fmt.Errorf("failed to serialize response: %w", err)
 	}
-	
+
 	return string(jsonResponse), nil
 }
 
@@ -0,0 +1,203 @@
+// internal/sandbox/cache.go
+package sandbox
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/CodeRushOJ/croj-sandbox/internal/util"
+	"github.com/google/uuid"
+)
+
+// compileCacheDirName is the directory under HostTempDir holding cached build outputs.
+const compileCacheDirName = "compile-cache"
+
+// ArtifactCache is a content-addressed, size-bounded LRU cache of compiled run directories.
+// Entries are keyed by the hash of language, compile command template and all submitted files, so a
+// resubmission of identical code can skip the compile phase entirely.
+type ArtifactCache struct {
+	dir      string
+	maxBytes int64
+
+	mu      sync.Mutex
+	size    int64
+	lru     *list.List               // front = most recently used
+	entries map[string]*list.Element // key -> element holding *cacheEntry
+}
+
+// cacheEntry describes one cached build.
+type cacheEntry struct {
+	key           string
+	dir           string
+	size          int64
+	compileOutput string
+	refs          int // number of in-progress restores; entries in use are never evicted
+}
+
+// NewArtifactCache creates a cache rooted at dir holding at most maxBytes of artifacts.
+// Any leftovers from a previous process are discarded, since the index lives in memory.
+func NewArtifactCache(dir string, maxBytes int64) (*ArtifactCache, error) {
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, fmt.Errorf("failed to reset compile cache dir %s: %w", dir, err)
+	}
+	if err := util.EnsureDir(dir); err != nil {
+		return nil, err
+	}
+	return &ArtifactCache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+	}, nil
+}
+
+// ArtifactKey returns the cache key for a submission. files holds the extra files of a
+// multi-file submission (path -> content) and may be nil.
+func ArtifactKey(language, compileCommand, sourceCode string, files map[string]string) string {
+	h := sha256.New()
+	for _, part := range []string{language, compileCommand, sourceCode} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		// 内容可能包含任意字节，写入长度避免不同文件集合产生相同的哈希输入
+		fmt.Fprintf(h, "%s\x00%d\x00", name, len(files[name]))
+		h.Write([]byte(files[name]))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Restore copies the cached build for key into runDir.
+// It returns the compile output recorded with the build and whether the key was found.
+func (c *ArtifactCache) Restore(key, runDir string) (compileOutput string, ok bool) {
+	c.mu.Lock()
+	elem, found := c.entries[key]
+	if !found {
+		c.mu.Unlock()
+		return "", false
+	}
+	c.lru.MoveToFront(elem)
+	entry := elem.Value.(*cacheEntry)
+	entry.refs++
+	c.mu.Unlock()
+
+	err := util.CopyDir(entry.dir, runDir)
+
+	c.mu.Lock()
+	entry.refs--
+	c.mu.Unlock()
+
+	if err != nil {
+		util.WarnLog("从编译缓存恢复失败 %s: %v", key, err)
+		c.remove(key)
+		return "", false
+	}
+	return entry.compileOutput, true
+}
+
+// Store records the contents of runDir as the build for key, evicting least recently used
+// entries until the cache fits within its size limit.
+func (c *ArtifactCache) Store(key, runDir, compileOutput string) error {
+	size, err := util.DirSize(runDir)
+	if err != nil {
+		return err
+	}
+	if size > c.maxBytes {
+		return fmt.Errorf("artifact of %d bytes exceeds compile cache size %d", size, c.maxBytes)
+	}
+
+	c.mu.Lock()
+	if elem, found := c.entries[key]; found {
+		c.lru.MoveToFront(elem)
+		c.mu.Unlock()
+		return nil
+	}
+	c.mu.Unlock()
+
+	// Copy outside the lock into a private directory, then publish it atomically
+	tmpDir := filepath.Join(c.dir, "tmp-"+uuid.New().String())
+	if err := util.CopyDir(runDir, tmpDir); err != nil {
+		os.RemoveAll(tmpDir)
+		return err
+	}
+	entryDir := filepath.Join(c.dir, key)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, found := c.entries[key]; found {
+		// Stored concurrently by another run
+		os.RemoveAll(tmpDir)
+		return nil
+	}
+	if err := os.Rename(tmpDir, entryDir); err != nil {
+		os.RemoveAll(tmpDir)
+		return fmt.Errorf("failed to publish cache entry %s: %w", key, err)
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{
+		key:           key,
+		dir:           entryDir,
+		size:          size,
+		compileOutput: compileOutput,
+	})
+	c.size += size
+	c.evictLocked()
+	return nil
+}
+
+// evictLocked drops least recently used, unreferenced entries while the cache is over its limit.
+func (c *ArtifactCache) evictLocked() {
+	for elem := c.lru.Back(); elem != nil && c.size > c.maxBytes; {
+		prev := elem.Prev()
+		entry := elem.Value.(*cacheEntry)
+		if entry.refs == 0 {
+			c.removeLocked(elem)
+			log.Printf("Evicted compile cache entry %s (%d bytes)", entry.key, entry.size)
+		}
+		elem = prev
+	}
+}
+
+// remove deletes the entry for key if present.
+func (c *ArtifactCache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, found := c.entries[key]; found && elem.Value.(*cacheEntry).refs == 0 {
+		c.removeLocked(elem)
+	}
+}
+
+func (c *ArtifactCache) removeLocked(elem *list.Element) {
+	entry := elem.Value.(*cacheEntry)
+	c.lru.Remove(elem)
+	delete(c.entries, entry.key)
+	c.size -= entry.size
+	if err := os.RemoveAll(entry.dir); err != nil {
+		util.WarnLog("删除编译缓存目录失败 %s: %v", entry.dir, err)
+	}
+}
+
+// Len returns the number of cached builds.
+func (c *ArtifactCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// Size returns the total size in bytes of cached builds.
+func (c *ArtifactCache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
@@ -0,0 +1,108 @@
+// internal/sandbox/cache_test.go
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// buildDir creates a run directory holding one file of size bytes.
+func buildDir(t *testing.T, size int) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main"), []byte(strings.Repeat("x", size)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// cachedKeys returns the sorted keys held by c.
+func cachedKeys(c *ArtifactCache) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keys := make([]string, 0, len(c.entries))
+	for key := range c.entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestArtifactCacheEviction(t *testing.T) {
+	tests := []struct {
+		name    string
+		restore []string // 存入 a、b 之后访问的条目
+		pinned  string   // 存入 c 时仍在恢复中的条目
+		want    []string
+	}{
+		{name: "least recently stored", want: []string{"b", "c"}},
+		{name: "restore refreshes", restore: []string{"a"}, want: []string{"a", "c"}},
+		{name: "restore order", restore: []string{"b", "a"}, want: []string{"a", "c"}},
+		{name: "entry in use is kept", pinned: "a", want: []string{"a", "c"}},
+		{name: "all older entries in use", restore: []string{"a"}, pinned: "b", want: []string{"b", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache, err := NewArtifactCache(filepath.Join(t.TempDir(), "cache"), 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, key := range []string{"a", "b"} {
+				if err := cache.Store(key, buildDir(t, 4), "output "+key); err != nil {
+					t.Fatalf("store %s: %v", key, err)
+				}
+			}
+			for _, key := range tt.restore {
+				if output, ok := cache.Restore(key, t.TempDir()); !ok || output != "output "+key {
+					t.Fatalf("restore %s: %q, %v", key, output, ok)
+				}
+			}
+			if tt.pinned != "" {
+				entry := cache.entries[tt.pinned].Value.(*cacheEntry)
+				entry.refs++
+				defer func() { entry.refs-- }()
+			}
+			if err := cache.Store("c", buildDir(t, 4), "output c"); err != nil {
+				t.Fatalf("store c: %v", err)
+			}
+
+			if got := cachedKeys(cache); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("cached %v, want %v", got, tt.want)
+			}
+			if cache.Size() != int64(4*len(tt.want)) {
+				t.Errorf("size %d, want %d", cache.Size(), 4*len(tt.want))
+			}
+			for _, key := range tt.want {
+				if _, err := os.Stat(filepath.Join(cache.dir, key, "main")); err != nil {
+					t.Errorf("entry %s: %v", key, err)
+				}
+			}
+		})
+	}
+}
+
+func TestArtifactCacheRejectsOversizedArtifact(t *testing.T) {
+	cache, err := NewArtifactCache(filepath.Join(t.TempDir(), "cache"), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Store("big", buildDir(t, 11), ""); err == nil {
+		t.Error("storing an artifact larger than the cache succeeded")
+	}
+	if cache.Len() != 0 || cache.Size() != 0 {
+		t.Errorf("cache holds %d entries of %d bytes after a rejected store", cache.Len(), cache.Size())
+	}
+}
+
+func TestArtifactCacheRestoreMissing(t *testing.T) {
+	cache, err := NewArtifactCache(filepath.Join(t.TempDir(), "cache"), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cache.Restore("missing", t.TempDir()); ok {
+		t.Error("restore of a missing key succeeded")
+	}
+}
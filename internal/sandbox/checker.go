@@ -0,0 +1,282 @@
+// internal/sandbox/checker.go
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CodeRushOJ/croj-sandbox/internal/util"
+)
+
+// Checker types selectable through CheckerSpec.Type
+const (
+	CheckerExact      = "exact"      // Whitespace-trimmed line-by-line equality (default)
+	CheckerTokens     = "tokens"     // Whitespace-separated token equality
+	CheckerFloat      = "float"      // Token compare with absolute/relative tolerance for numbers
+	CheckerIgnoreCase = "ignorecase" // Exact compare ignoring letter case
+	CheckerUnordered  = "unordered"  // Same multiset of lines in any order
+	CheckerCustom     = "custom"     // testlib-style program: checker <input> <output> <answer>
+)
+
+// DefaultFloatEpsilon is the tolerance used by the float checker when none is given.
+const DefaultFloatEpsilon = 1e-6
+
+// CheckerSpec selects and configures the output checker of a request.
+type CheckerSpec struct {
+	Type       string  `json:"type"`       // One of the Checker* constants (default: exact)
+	AbsEpsilon float64 `json:"absEpsilon"` // Float checker: absolute tolerance
+	RelEpsilon float64 `json:"relEpsilon"` // Float checker: relative tolerance
+	Language   string  `json:"language"`   // Custom checker: language of SourceCode (default: cpp)
+	SourceCode string  `json:"sourceCode"` // Custom checker: checker program source
+}
+
+// CheckResult is the verdict of a checker on one output.
+type CheckResult struct {
+	Status  Status  // StatusAccepted, StatusWrongAnswer or StatusSandboxError (checker failure)
+	Message string  // Human readable explanation from the checker
+	Points  float64 // Wrong Answer only: share (0..1) of the case's score earned by a partially correct output
+}
+
+// Checker decides whether a program's output is an acceptable answer.
+type Checker interface {
+	Check(ctx context.Context, input, output, answer string) CheckResult
+}
+
+func accepted(format string, v ...interface{}) CheckResult {
+	return CheckResult{Status: StatusAccepted, Message: fmt.Sprintf(format, v...)}
+}
+
+func wrongAnswer(format string, v ...interface{}) CheckResult {
+	return CheckResult{Status: StatusWrongAnswer, Message: fmt.Sprintf(format, v...)}
+}
+
+// exactChecker compares outputs after NormalizeString, the sandbox's historic behaviour.
+type exactChecker struct {
+	ignoreCase bool
+}
+
+func (c exactChecker) Check(_ context.Context, _, output, answer string) CheckResult {
+	out, ans := util.NormalizeString(output), util.NormalizeString(answer)
+	if out == ans || (c.ignoreCase && strings.EqualFold(out, ans)) {
+		return accepted("ok")
+	}
+	outLines, ansLines := strings.Split(out, "\n"), strings.Split(ans, "\n")
+	for i := 0; i < len(outLines) && i < len(ansLines); i++ {
+		if outLines[i] != ansLines[i] && !(c.ignoreCase && strings.EqualFold(outLines[i], ansLines[i])) {
+			return wrongAnswer("line %d differs: expected %q, found %q", i+1, ansLines[i], outLines[i])
+		}
+	}
+	return wrongAnswer("expected %d lines, found %d", len(ansLines), len(outLines))
+}
+
+// tokenChecker compares whitespace-separated tokens, optionally with numeric tolerance.
+type tokenChecker struct {
+	float  bool
+	absEps float64
+	relEps float64
+}
+
+func (c tokenChecker) Check(_ context.Context, _, output, answer string) CheckResult {
+	outTokens, ansTokens := strings.Fields(output), strings.Fields(answer)
+	for i := 0; i < len(outTokens) && i < len(ansTokens); i++ {
+		if !c.tokenEqual(outTokens[i], ansTokens[i]) {
+			return wrongAnswer("token %d differs: expected %q, found %q", i+1, ansTokens[i], outTokens[i])
+		}
+	}
+	if len(outTokens) != len(ansTokens) {
+		return wrongAnswer("expected %d tokens, found %d", len(ansTokens), len(outTokens))
+	}
+	return accepted("ok %d tokens", len(ansTokens))
+}
+
+func (c tokenChecker) tokenEqual(out, ans string) bool {
+	if out == ans {
+		return true
+	}
+	if !c.float {
+		return false
+	}
+	outVal, err1 := strconv.ParseFloat(out, 64)
+	ansVal, err2 := strconv.ParseFloat(ans, 64)
+	if err1 != nil || err2 != nil || math.IsNaN(outVal) || math.IsNaN(ansVal) {
+		return false
+	}
+	diff := math.Abs(outVal - ansVal)
+	return diff <= c.absEps || diff <= c.relEps*math.Abs(ansVal)
+}
+
+// unorderedChecker accepts any permutation of the expected lines.
+type unorderedChecker struct{}
+
+func (unorderedChecker) Check(_ context.Context, _, output, answer string) CheckResult {
+	outLines := strings.Split(util.NormalizeString(output), "\n")
+	ansLines := strings.Split(util.NormalizeString(answer), "\n")
+	if len(outLines) != len(ansLines) {
+		return wrongAnswer("expected %d lines, found %d", len(ansLines), len(outLines))
+	}
+	sort.Strings(outLines)
+	sort.Strings(ansLines)
+	for i := range ansLines {
+		if outLines[i] != ansLines[i] {
+			return wrongAnswer("line %q expected but not found", ansLines[i])
+		}
+	}
+	return accepted("ok %d lines", len(ansLines))
+}
+
+// testlib exit codes. Like testlib, every code other than OK and _fail rejects the answer:
+// WA (1), PE (2), _dirt (4), _unexpected_eof (8) and the partial codes are all Wrong Answer.
+const (
+	checkerExitOK     = 0
+	checkerExitFail   = 3 // _fail: the checker itself failed, the only code judged as a sandbox error
+	checkerExitPoints = 7 // quitp: partially correct, the message starts with "points <value>"
+)
+
+// checkerRejected reports whether a testlib exit code rejects the answer. Negative codes mean the
+// checker was killed by a signal, which is a failure of the checker.
+func checkerRejected(exitCode int) bool {
+	return exitCode > checkerExitOK && exitCode != checkerExitFail
+}
+
+// checkerPoints returns the share of the case's score awarded by a testlib-style partial verdict:
+// exit code checkerExitPoints with a message "points <value> ...", where value is a fraction from 0 to 1.
+func checkerPoints(exitCode int, message string) float64 {
+	if exitCode != checkerExitPoints {
+		return 0
+	}
+	fields := strings.Fields(message)
+	if len(fields) < 2 || fields[0] != "points" {
+		return 0
+	}
+	points, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil || math.IsNaN(points) || points <= 0 {
+		return 0
+	}
+	return math.Min(points, 1)
+}
+
+// customChecker runs a compiled, trusted checker program as "checker <input> <output> <answer>".
+type customChecker struct {
+	runner *Runner
+	prog   *compiledProgram
+	cfg    Config
+}
+
+func (c *customChecker) Check(ctx context.Context, input, output, answer string) CheckResult {
+	checkDir, err := os.MkdirTemp(c.prog.runDir, "check-")
+	if err != nil {
+		return CheckResult{Status: StatusSandboxError, Message: fmt.Sprintf("failed to create checker dir: %v", err)}
+	}
+	defer os.RemoveAll(checkDir)
+	// 检查器以自己的非特权用户运行，需要能读取输入文件
+	if err := os.Chmod(checkDir, 0755); err != nil {
+		return CheckResult{Status: StatusSandboxError, Message: fmt.Sprintf("failed to create checker dir: %v", err)}
+	}
+
+	files := []struct{ name, content string }{
+		{"input.txt", input}, {"output.txt", output}, {"answer.txt", answer},
+	}
+	args := make([]string, 0, len(files))
+	for _, f := range files {
+		path := filepath.Join(checkDir, f.name)
+		if err := os.WriteFile(path, []byte(f.content), 0644); err != nil {
+			return CheckResult{Status: StatusSandboxError, Message: fmt.Sprintf("failed to write checker %s: %v", f.name, err)}
+		}
+		args = append(args, path)
+	}
+
+	runCmd, err := c.runner.runCommand(c.prog, c.cfg.DefaultExecuteMemoryLimit/1024)
+	if err != nil {
+		return CheckResult{Status: StatusSandboxError, Message: err.Error()}
+	}
+	// 检查器与用户程序一样占用执行槽位，但在自己的目录中以不同于用户程序的非特权用户运行
+	slot, err := c.runner.execSlots.Acquire(ctx)
+	if err != nil {
+		return CheckResult{Status: StatusSandboxError, Message: fmt.Sprintf("failed to acquire checker slot: %v", err)}
+	}
+	defer c.runner.execSlots.Release(slot)
+	executor, err := c.runner.slotExecutor(c.cfg, slot, c.prog.runDir, c.runner.checkerCredentials)
+	if err != nil {
+		return CheckResult{Status: StatusSandboxError, Message: err.Error()}
+	}
+	res := executor.Execute(ctx, append(runCmd, args...), c.prog.langCfg.Run.Env, nil)
+
+	message := strings.TrimSpace(res.Stderr)
+	if message == "" {
+		message = strings.TrimSpace(res.Stdout)
+	}
+	switch {
+	case res.Status == StatusTimeLimitExceeded || res.Status == StatusMemoryLimitExceeded || res.Status == StatusSandboxError:
+		return CheckResult{Status: StatusSandboxError, Message: fmt.Sprintf("checker failed: %s: %s", res.Status, res.Error)}
+	case res.ExitCode == checkerExitOK:
+		return CheckResult{Status: StatusAccepted, Message: message}
+	case checkerRejected(res.ExitCode):
+		return CheckResult{Status: StatusWrongAnswer, Message: message, Points: checkerPoints(res.ExitCode, message)}
+	default:
+		return CheckResult{Status: StatusSandboxError, Message: fmt.Sprintf("checker failed (exit code %d): %s", res.ExitCode, message)}
+	}
+}
+
+// newChecker builds the checker described by spec. Custom checkers are compiled once here;
+// the returned cleanup (if non-nil) removes their build directory.
+func (r *Runner) newChecker(ctx context.Context, spec *CheckerSpec, cfg Config) (Checker, func(), error) {
+	if spec == nil {
+		return exactChecker{}, nil, nil
+	}
+
+	switch spec.Type {
+	case "", CheckerExact:
+		return exactChecker{}, nil, nil
+	case CheckerIgnoreCase:
+		return exactChecker{ignoreCase: true}, nil, nil
+	case CheckerTokens:
+		return tokenChecker{}, nil, nil
+	case CheckerFloat:
+		checker := tokenChecker{float: true, absEps: spec.AbsEpsilon, relEps: spec.RelEpsilon}
+		if checker.absEps <= 0 && checker.relEps <= 0 {
+			checker.absEps = DefaultFloatEpsilon
+			checker.relEps = DefaultFloatEpsilon
+		}
+		return checker, nil, nil
+	case CheckerUnordered:
+		return unorderedChecker{}, nil, nil
+	case CheckerCustom:
+		if spec.SourceCode == "" {
+			return nil, nil, fmt.Errorf("%w: custom checker has no source code", ErrCheckerFailed)
+		}
+		language := spec.Language
+		if language == "" {
+			language = "cpp"
+		}
+		checkerCfg := cfg
+		checkerCfg.Checker = nil
+		checkerCfg.SourceFiles = nil
+		checkerCfg.SourceArchive = nil
+		checkerCfg.Grader = nil
+		checkerCfg.OutputSink = nil
+		checkerCfg.StdinReader = nil
+		checkerCfg.Language = language
+		checkerCfg.DefaultExecuteTimeLimit = time.Duration(DefaultCheckerTimeLimitSec) * time.Second
+		checkerCfg.UserSpecifiedTimeout = true
+		checkerCfg.WallTimeLimit = 0
+		checkerCfg.DefaultExecuteMemoryLimit = int64(DefaultMemoryLimitMB) * 1024 * 1024
+
+		prog, cleanup, failed := r.compile(ctx, language, spec.SourceCode, checkerCfg)
+		if failed != nil {
+			if cleanup != nil {
+				cleanup()
+			}
+			return nil, nil, fmt.Errorf("%w: %s: %s", ErrCheckerFailed, failed.Status, failed.Error)
+		}
+		return &customChecker{runner: r, prog: prog, cfg: checkerCfg}, cleanup, nil
+	default:
+		return nil, nil, fmt.Errorf("%w: unknown checker type '%s'", ErrCheckerFailed, spec.Type)
+	}
+}
@@ -0,0 +1,57 @@
+// internal/sandbox/checker_test.go
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// uidScript prints the real UID of the shell from /proc/self/status.
+const uidScript = `while read key uid rest; do if [ "$key" = "Uid:" ]; then echo "$uid"; fi; done < /proc/self/status
+`
+
+func TestCustomCheckerExitCodes(t *testing.T) {
+	requireSandbox(t)
+	cfg := DefaultConfig()
+	cfg.Languages = map[string]LanguageConfig{"sh": shellLanguage}
+	// 检查器以答案文件中的数字作为退出码
+	cfg.Checker = &CheckerSpec{Type: CheckerCustom, Language: "sh", SourceCode: `read code < "$3"; exit "$code"`}
+	r := testRunner(t, cfg)
+
+	// testlib: 只有 _fail (3) 和被信号终止是检查器错误，_dirt (4) 等其余非零退出码都是答案错误
+	want := map[int]Status{
+		0: StatusAccepted, 1: StatusWrongAnswer, 2: StatusWrongAnswer, 3: StatusSandboxError,
+		4: StatusWrongAnswer, 5: StatusWrongAnswer, 6: StatusWrongAnswer, 7: StatusWrongAnswer, 8: StatusWrongAnswer,
+	}
+	cases := make([]TestCase, len(want))
+	for code := range want {
+		answer := fmt.Sprint(code)
+		cases[code] = TestCase{ExpectedOutput: &answer}
+	}
+	res := r.RunTestCases(context.Background(), "sh", "echo", cases)
+	for code, caseRes := range res.TestCaseResults {
+		if caseRes.Status != want[code] {
+			t.Errorf("checker exit code %d: status %s (%s), want %s", code, caseRes.Status, caseRes.Error, want[code])
+		}
+	}
+}
+
+func TestCustomCheckerRunsAsOwnUser(t *testing.T) {
+	requireSandbox(t)
+	cfg := DefaultConfig()
+	cfg.Languages = map[string]LanguageConfig{"sh": shellLanguage}
+	// 用户程序输出自己的UID，检查器比较它与自己的UID
+	cfg.Checker = &CheckerSpec{Type: CheckerCustom, Language: "sh", SourceCode: `read user < "$2"
+checker=$(` + strings.TrimSpace(uidScript) + `)
+echo "checker $checker, user $user" >&2
+[ -n "$user" ] && [ "$checker" != "$user" ]`}
+	r := testRunner(t, cfg)
+
+	answer := ""
+	res := r.RunTestCases(context.Background(), "sh", uidScript, []TestCase{{ExpectedOutput: &answer}})
+	if res.Status != StatusAccepted {
+		t.Errorf("status %s (%s): %s", res.Status, res.Error, res.TestCaseResults[0].CheckerMessage)
+	}
+}
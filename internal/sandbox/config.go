@@ -2,25 +2,35 @@
 package sandbox
 
 import (
-    "time"
+	"io"
+	"runtime"
+	"time"
 )
 
 // Command template placeholders
 const (
-	PlaceholderSrcPath  = "{{SRC_PATH}}"  // Source code file path
-	PlaceholderExePath  = "{{EXE_PATH}}"  // Executable/output file path
-	PlaceholderWorkDir  = "{{WORK_DIR}}"  // Working directory path
-	PlaceholderExeDir   = "{{EXE_DIR}}"   // Directory containing the executable
-	PlaceholderMaxMemory = "{{MAX_MEM}}"  // Maximum memory in KB
+	PlaceholderSrcPath     = "{{SRC_PATH}}"     // Source code file path
+	PlaceholderExePath     = "{{EXE_PATH}}"     // Executable/output file path
+	PlaceholderWorkDir     = "{{WORK_DIR}}"     // Working directory path
+	PlaceholderExeDir      = "{{EXE_DIR}}"      // Directory containing the executable
+	PlaceholderMaxMemory   = "{{MAX_MEM}}"      // Maximum memory in KB
+	PlaceholderGraderFiles = "{{GRADER_FILES}}" // Grader sources passed to the compiler (see GraderSpec)
 )
 
 const (
 	// --- Default Execution Limits ---
-	DefaultCompileTimeLimitSec = 10 // Default compile timeout in seconds
-	DefaultExecuteTimeLimitSec = 3  // Default execution timeout in seconds
-	DefaultMaxStdoutKB         = 64 // Default max stdout size in KB
-	DefaultMaxStderrKB         = 64 // Default max stderr size in KB
-	DefaultMemoryLimitMB       = 512 // Default memory limit in MB
+	DefaultCompileTimeLimitSec = 10    // Default compile timeout in seconds
+	DefaultExecuteTimeLimitSec = 3     // Default execution timeout in seconds
+	DefaultMaxStdoutKB         = 64    // Default max stdout size in KB
+	DefaultMaxStderrKB         = 64    // Default max stderr size in KB
+	DefaultMemoryLimitMB       = 512   // Default memory limit in MB
+	DefaultCompileCacheMB      = 256   // Default compiled-artifact cache size in MB
+	DefaultCheckerTimeLimitSec = 5     // Default timeout for custom checker programs in seconds
+	DefaultRunUIDBase          = 10000 // First unprivileged UID/GID used to run user programs
+	DefaultWallTimeFactor      = 3     // Wall-clock limit as a multiple of the CPU time limit
+	DefaultStackLimitMB        = 256   // Default stack size limit in MB (deep recursion needs more than the usual 8MB)
+	DefaultFileSizeLimitMB     = 64    // Default max size of a single file written by the program, in MB
+	DefaultOpenFilesLimit      = 256   // Default max number of open file descriptors
 
 	// --- Host Environment ---
 	DefaultHostTempDir = "/tmp/croj-sandbox-local-runs" // Default host temp directory
@@ -28,18 +38,42 @@ const (
 
 // CompileConfig defines how to compile a language source file
 type CompileConfig struct {
-	SrcName       string `json:"srcName"`       // Source file name (e.g., "main.go")
-	ExeName       string `json:"exeName"`       // Output executable name
-	CompileCommand string `json:"command"`      // Compile command template
-	TimeoutSec    int    `json:"timeoutSec"`   // Compile timeout in seconds (0 = use default)
+	SrcName        string `json:"srcName"`        // Source file name (e.g., "main.go")
+	ExeName        string `json:"exeName"`        // Output executable name
+	CompileCommand string `json:"command"`        // Compile command template
+	ProjectCommand string `json:"projectCommand"` // Compile command template for multi-file submissions (empty = CompileCommand)
+	TimeoutSec     int    `json:"timeoutSec"`     // Compile timeout in seconds (0 = use default)
+}
+
+// CommandFor returns the compile command template for a submission, using ProjectCommand
+// when the submission carries extra files and the language defines one.
+func (cc CompileConfig) CommandFor(multiFile bool) string {
+	if multiFile && cc.ProjectCommand != "" {
+		return cc.ProjectCommand
+	}
+	return cc.CompileCommand
 }
 
 // RunConfig defines how to run a compiled or interpreted language
 type RunConfig struct {
-	Command    string            `json:"command"`    // Run command template
-	Env        map[string]string `json:"env"`        // Environment variables
-	TimeoutSec int               `json:"timeoutSec"` // Execution timeout in seconds (0 = use default)
-	MemoryMB   int               `json:"memoryMB"`   // Memory limit in MB (0 = use default)
+	Command     string            `json:"command"`     // Run command template
+	Env         map[string]string `json:"env"`         // Environment variables
+	TimeoutSec  int               `json:"timeoutSec"`  // Execution timeout in seconds (0 = use default); legacy alias of CPUTimeSec
+	CPUTimeSec  int               `json:"cpuTimeSec"`  // CPU time limit in seconds (0 = TimeoutSec or default)
+	WallTimeSec int               `json:"wallTimeSec"` // Wall-clock time limit in seconds (0 = derived from the CPU limit)
+	MemoryMB    int               `json:"memoryMB"`    // Memory limit in MB (0 = use default)
+	StackMB     int               `json:"stackMB"`     // Stack size limit in MB (0 = use default, -1 = unlimited)
+	FileSizeMB  int               `json:"fileSizeMB"`  // Max size of a file written by the program in MB (0 = use default)
+	OpenFiles   int               `json:"openFiles"`   // Max open file descriptors (0 = use default)
+	Processes   int               `json:"processes"`   // Max processes/threads (0 = the language's security profile default)
+}
+
+// ResourceLimits holds the setrlimit-based limits of a run, applied before the program starts.
+type ResourceLimits struct {
+	StackBytes    int64 // Stack size limit in bytes (-1 = unlimited)
+	FileSizeBytes int64 // Max size of a file written by the program in bytes; exceeding it is an Output Limit Exceeded
+	OpenFiles     int   // Max open file descriptors
+	Processes     int   // Max processes/threads (0 = the language's security profile default)
 }
 
 // LanguageConfig holds configuration for a specific programming language
@@ -63,7 +97,7 @@ func (lc *LanguageConfig) GetExecuteTimeout(defaultTimeout time.Duration, userSp
 	if len(userSpecified) > 0 && userSpecified[0] {
 		return defaultTimeout
 	}
-	
+
 	// 否则检查语言配置
 	if lc.Run.TimeoutSec <= 0 {
 		return defaultTimeout
@@ -71,6 +105,76 @@ func (lc *LanguageConfig) GetExecuteTimeout(defaultTimeout time.Duration, userSp
 	return time.Duration(lc.Run.TimeoutSec) * time.Second
 }
 
+// GetCPUTimeLimit returns the CPU time limit of a run: a user-specified limit (cfg.DefaultExecuteTimeLimit)
+// wins, then the language's CPUTimeSec/TimeoutSec, then cfg.CPUTimeLimit, then cfg.DefaultExecuteTimeLimit.
+func (lc *LanguageConfig) GetCPUTimeLimit(cfg Config) time.Duration {
+	if cfg.UserSpecifiedTimeout {
+		return cfg.DefaultExecuteTimeLimit
+	}
+	if lc.Run.CPUTimeSec > 0 {
+		return time.Duration(lc.Run.CPUTimeSec) * time.Second
+	}
+	if lc.Run.TimeoutSec > 0 {
+		return time.Duration(lc.Run.TimeoutSec) * time.Second
+	}
+	if cfg.CPUTimeLimit > 0 {
+		return cfg.CPUTimeLimit
+	}
+	return cfg.DefaultExecuteTimeLimit
+}
+
+// GetWallTimeLimit returns the wall-clock limit for a run with the given CPU time limit:
+// cfg.WallTimeLimit, then the language's WallTimeSec, then DefaultWallTimeFactor times the CPU limit.
+// The result is never below the CPU limit.
+func (lc *LanguageConfig) GetWallTimeLimit(cpuLimit time.Duration, cfg Config) time.Duration {
+	wall := cpuLimit * DefaultWallTimeFactor
+	if cfg.WallTimeLimit > 0 {
+		wall = cfg.WallTimeLimit
+	} else if lc.Run.WallTimeSec > 0 {
+		wall = time.Duration(lc.Run.WallTimeSec) * time.Second
+	}
+	if wall < cpuLimit {
+		wall = cpuLimit
+	}
+	return wall
+}
+
+// GetResourceLimits returns the setrlimit-based limits of a run: limits set on cfg (per request)
+// win, then the language's RunConfig, then the package defaults.
+func (lc *LanguageConfig) GetResourceLimits(cfg Config) ResourceLimits {
+	limits := ResourceLimits{
+		StackBytes:    int64(DefaultStackLimitMB) * 1024 * 1024,
+		FileSizeBytes: int64(DefaultFileSizeLimitMB) * 1024 * 1024,
+		OpenFiles:     DefaultOpenFilesLimit,
+		Processes:     lc.Run.Processes,
+	}
+	if lc.Run.StackMB < 0 {
+		limits.StackBytes = -1
+	} else if lc.Run.StackMB > 0 {
+		limits.StackBytes = int64(lc.Run.StackMB) * 1024 * 1024
+	}
+	if lc.Run.FileSizeMB > 0 {
+		limits.FileSizeBytes = int64(lc.Run.FileSizeMB) * 1024 * 1024
+	}
+	if lc.Run.OpenFiles > 0 {
+		limits.OpenFiles = lc.Run.OpenFiles
+	}
+
+	if cfg.StackLimit != 0 {
+		limits.StackBytes = cfg.StackLimit
+	}
+	if cfg.FileSizeLimit > 0 {
+		limits.FileSizeBytes = cfg.FileSizeLimit
+	}
+	if cfg.OpenFilesLimit > 0 {
+		limits.OpenFiles = cfg.OpenFilesLimit
+	}
+	if cfg.ProcessLimit > 0 {
+		limits.Processes = cfg.ProcessLimit
+	}
+	return limits
+}
+
 // GetMemoryLimit returns the memory limit in bytes, using default if not set
 func (lc *LanguageConfig) GetMemoryLimit(defaultLimit int64) int64 {
 	if lc.Run.MemoryMB <= 0 {
@@ -82,47 +186,114 @@ func (lc *LanguageConfig) GetMemoryLimit(defaultLimit int64) int64 {
 // Config holds the configuration for the sandbox system.
 type Config struct {
 	// Host Environment
-	HostTempDir            string                    `json:"hostTempDir"`
-	DefaultCompileTimeLimit time.Duration            `json:"defaultCompileTimeLimit"`
-	DefaultExecuteTimeLimit time.Duration            `json:"defaultExecuteTimeLimit"`
-	DefaultExecuteMemoryLimit int64                  `json:"defaultExecuteMemoryLimit"`
-	MaxStdoutSize          int64                     `json:"maxStdoutSize"`
-	MaxStderrSize          int64                     `json:"maxStderrSize"`
-	Languages              map[string]LanguageConfig `json:"languages"`
-	
+	HostTempDir               string                    `json:"hostTempDir"`
+	DefaultCompileTimeLimit   time.Duration             `json:"defaultCompileTimeLimit"`
+	DefaultExecuteTimeLimit   time.Duration             `json:"defaultExecuteTimeLimit"`
+	DefaultExecuteMemoryLimit int64                     `json:"defaultExecuteMemoryLimit"`
+	MaxStdoutSize             int64                     `json:"maxStdoutSize"`
+	MaxStderrSize             int64                     `json:"maxStderrSize"`
+	Languages                 map[string]LanguageConfig `json:"languages"`
+
+	// 编译产物缓存大小上限（字节），0 表示禁用缓存
+	CompileCacheMaxBytes int64 `json:"compileCacheMaxBytes"`
+
+	// 并发限制：同时进行的编译数和执行数（0 表示不限制），超出时排队等待
+	MaxParallelCompiles   int `json:"maxParallelCompiles"`
+	MaxParallelExecutions int `json:"maxParallelExecutions"`
+	// 可选：为每个执行槽位绑定的CPU核心，第i个槽位绑定到 ExecutionCPUs[i]
+	// 非空且 MaxParallelExecutions 为0时，执行并发数等于核心数
+	ExecutionCPUs []int `json:"executionCPUs"`
+	// 同时运行的交互式会话（实时标准输入）程序数，使用独立的槽位，不占用判题的执行槽位，也不绑定CPU（0 表示不限制）
+	MaxParallelSessions int `json:"maxParallelSessions"`
+	// 运行用户程序的非特权UID/GID池：第i个执行槽位使用 RunUIDBase+i / RunGIDBase+i（0 表示不切换用户），
+	// 该槽位中的自定义检查器使用 RunUIDBase+N+i / RunGIDBase+N+i，第i个会话槽位使用 RunUIDBase+2N+i / RunGIDBase+2N+i（N 为执行槽位数）
+	// 需要以root运行沙箱，且执行数（MaxParallelExecutions 或 ExecutionCPUs）和会话数必须受限，否则 NewRunner 返回错误
+	RunUIDBase int `json:"runUIDBase"`
+	RunGIDBase int `json:"runGIDBase"`
+
+	// CPU时间限制（用户态+内核态，含子进程），0 表示使用 DefaultExecuteTimeLimit
+	CPUTimeLimit time.Duration `json:"cpuTimeLimit"`
+	// 墙钟时间限制，防止睡眠或阻塞的程序长期占用执行槽位，0 表示CPU时间限制的 DefaultWallTimeFactor 倍
+	WallTimeLimit time.Duration `json:"wallTimeLimit"`
+
+	// setrlimit限制，在用户程序启动前应用，0 表示使用语言配置或默认值
+	StackLimit     int64 `json:"stackLimit"`     // 栈大小上限（字节），-1 表示不限制
+	FileSizeLimit  int64 `json:"fileSizeLimit"`  // 单个写入文件的大小上限（字节）
+	OpenFilesLimit int   `json:"openFilesLimit"` // 打开的文件描述符数上限
+	ProcessLimit   int   `json:"processLimit"`   // 进程/线程数上限
+
 	// 保留旧的字段名称以兼容API
-	CompileTimeout         time.Duration  // 兼容字段
-	ExecTimeout            time.Duration  // 兼容字段
-	SrcFileName            string         // 兼容字段
+	CompileTimeout time.Duration // 兼容字段
+	ExecTimeout    time.Duration // 兼容字段
+	SrcFileName    string        // 兼容字段
 
 	// 是否使用用户指定的超时（优先级高于语言配置）
 	UserSpecifiedTimeout bool
 
+	// 输出检查器（为空时使用精确比较）
+	Checker *CheckerSpec
+
+	// 交互器（非空时以交互模式运行，由交互器判定结果）
+	Interactor *InteractorSpec
+
+	// 文件输入输出：运行前写入工作目录的输入文件（文件名 -> 内容），运行后收集的输出文件，
+	// 以及输出文件的期望内容（文件名 -> 内容，由输出检查器逐个比较）
+	InputFiles          map[string]string
+	OutputFiles         []string
+	ExpectedOutputFiles map[string]string
+	MaxOutputFileSize   int64 // 每个收集的输出文件的大小上限（字节），0 表示与 MaxStdoutSize 相同
+
+	// 多文件提交：编译前写入工作目录的额外源文件（相对路径 -> 内容），以及 tar/tar.gz/zip 格式的源码压缩包。
+	// 同一路径同时出现时以 SourceFiles 为准；源代码为空时主源文件必须包含在其中
+	SourceFiles   map[string]string
+	SourceArchive []byte
+
+	// 题目提供的评测附加文件（如 grader.cpp、solution.h），不会出现在返回结果中
+	Grader *GraderSpec
+
+	// 子任务计分（仅多测试用例评测），为空时按测试用例分数求和
+	Subtasks []Subtask
+
+	// 多测试用例的运行策略（RunAll / RunStopOnFailure / RunSamplesFirst），为空时运行全部用例
+	RunPolicy string
+
+	// 实时接收编译输出和用户程序的标准输出/标准错误（可为空）
+	OutputSink OutputSink
+
+	// 实时标准输入（交互式会话），非空时代替请求的标准输入
+	StdinReader io.Reader
+
 	// 安全相关设置
-	Language           string // 执行的编程语言
-	StrictSecurity     bool   // 使用严格的安全限制
-	NoSecurity         bool   // 完全禁用安全限制
-	DisableNetworking  bool   // 禁用网络访问
-	DisableFileWrite   bool   // 禁用文件写入（只读模式）
-	AllowedPaths       []string // 允许访问的路径列表
-	SeccompProfile     string // 自定义seccomp配置文件路径
+	Language          string   // 执行的编程语言
+	StrictSecurity    bool     // 使用严格的安全限制
+	NoSecurity        bool     // 完全禁用安全限制
+	DisableNetworking bool     // 禁用网络访问
+	DisableFileWrite  bool     // 禁用文件写入（只读模式）
+	AllowedPaths      []string // 允许访问的路径列表
+	SeccompProfile    string   // 自定义seccomp配置文件路径
 }
 
 // DefaultConfig returns a new Config struct with default values and language settings.
 func DefaultConfig() Config {
 	cfg := Config{
-		HostTempDir:            DefaultHostTempDir,
-		DefaultCompileTimeLimit: time.Duration(DefaultCompileTimeLimitSec) * time.Second,
-		DefaultExecuteTimeLimit: time.Duration(DefaultExecuteTimeLimitSec) * time.Second,
+		HostTempDir:               DefaultHostTempDir,
+		DefaultCompileTimeLimit:   time.Duration(DefaultCompileTimeLimitSec) * time.Second,
+		DefaultExecuteTimeLimit:   time.Duration(DefaultExecuteTimeLimitSec) * time.Second,
 		DefaultExecuteMemoryLimit: int64(DefaultMemoryLimitMB) * 1024 * 1024,
-		MaxStdoutSize:          int64(DefaultMaxStdoutKB) * 1024,
-		MaxStderrSize:          int64(DefaultMaxStderrKB) * 1024,
-		Languages:              make(map[string]LanguageConfig),
-		
+		MaxStdoutSize:             int64(DefaultMaxStdoutKB) * 1024,
+		MaxStderrSize:             int64(DefaultMaxStderrKB) * 1024,
+		Languages:                 make(map[string]LanguageConfig),
+		CompileCacheMaxBytes:      int64(DefaultCompileCacheMB) * 1024 * 1024,
+		MaxParallelCompiles:       runtime.NumCPU(),
+		MaxParallelExecutions:     runtime.NumCPU(),
+		MaxParallelSessions:       runtime.NumCPU(),
+		RunUIDBase:                DefaultRunUIDBase,
+		RunGIDBase:                DefaultRunUIDBase,
+
 		// 为了兼容API，保留旧字段值
-		CompileTimeout:         time.Duration(DefaultCompileTimeLimitSec) * time.Second,
-		ExecTimeout:            time.Duration(DefaultExecuteTimeLimitSec) * time.Second,
-		SrcFileName:            "main.go",
+		CompileTimeout: time.Duration(DefaultCompileTimeLimitSec) * time.Second,
+		ExecTimeout:    time.Duration(DefaultExecuteTimeLimitSec) * time.Second,
+		SrcFileName:    "main.go",
 
 		// 默认安全设置
 		StrictSecurity:    true,
@@ -136,4 +307,4 @@ func DefaultConfig() Config {
 	ConfigureDefaultLanguages(&cfg)
 
 	return cfg
-}
\ No newline at end of file
+}
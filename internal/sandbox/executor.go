@@ -8,22 +8,30 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math"
+	"os"
 	"os/exec"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
-	"github.com/CodeRushOJ/croj-sandbox/internal/util"
 	"github.com/CodeRushOJ/croj-sandbox/internal/security"
+	"github.com/CodeRushOJ/croj-sandbox/internal/util"
 )
 
 // Executor handles executing commands with appropriate resource limits.
 type Executor struct {
-	cfg Config
+	cfg     Config
+	cpu     int    // CPU core the process is pinned to (-1 = no pinning)
+	workDir string // Working directory of the process; the only host directory writable inside the sandbox
+	uid     int    // Unprivileged UID the process runs as (-1 = same as the sandbox)
+	gid     int    // GID the process runs as (-1 = same as the sandbox)
 }
 
 // NewExecutor creates a new executor instance.
 func NewExecutor(cfg Config) *Executor {
-	return &Executor{cfg: cfg}
+	return &Executor{cfg: cfg, cpu: -1, uid: -1, gid: -1}
 }
 
 // Execute runs the provided command with resource constraints.
@@ -31,31 +39,110 @@ func NewExecutor(cfg Config) *Executor {
 // env: Optional environment variables
 // stdinData: Optional standard input data
 func (e *Executor) Execute(ctx context.Context, runCmd []string, env map[string]string, stdinData *string) Result {
+	var pio processIO
+	if e.cfg.StdinReader != nil {
+		// 交互式会话：输入随到随写，读到EOF时关闭程序的标准输入
+		pio.stdin = e.cfg.StdinReader
+	} else if stdinData != nil {
+		pio.stdin = strings.NewReader(*stdinData)
+	}
+	return e.run(ctx, runCmd, env, pio)
+}
+
+// processWaitDelay bounds how long Wait keeps copying output after the process exits,
+// in case leftover descendants still hold the stdout/stderr pipes.
+const processWaitDelay = 500 * time.Millisecond
+
+// processIO describes how a process's standard streams are wired.
+type processIO struct {
+	stdin           io.Reader   // nil = no stdin
+	stdout          io.Writer   // nil = captured into Result.Stdout (limited by MaxStdoutSize)
+	closeAfterStart []io.Closer // parent copies of fds handed to the child
+	closeAfterWait  []io.Closer // closed once the process and its copy goroutines are done
+}
+
+// closeAll closes every closer, ignoring errors
+func closeAll(closers []io.Closer) {
+	for _, c := range closers {
+		c.Close()
+	}
+}
+
+// run executes runCmd with the given stream wiring and builds its Result.
+func (e *Executor) run(ctx context.Context, runCmd []string, env map[string]string, pio processIO) Result {
+	defer closeAll(pio.closeAfterWait)
 	if len(runCmd) == 0 {
+		closeAll(pio.closeAfterStart)
 		return NewResult(StatusSandboxError, fmt.Errorf("empty command provided to executor"))
 	}
 
 	util.DebugLog("执行命令: %v", runCmd)
-	execCmd := exec.CommandContext(ctx, runCmd[0], runCmd[1:]...)
 
 	// Set environment variables if provided
+	var execEnv []string // nil = inherit the current environment
 	if len(env) > 0 {
-		execEnv := execCmd.Environ() // Start with current environment
+		execEnv = os.Environ() // Start with current environment
 		for k, v := range env {
 			execEnv = append(execEnv, fmt.Sprintf("%s=%s", k, v))
 		}
+	}
+
+	// 创建本次执行的安全上下文，限制由初始化助手在用户程序启动前应用
+	var sb *security.Sandbox
+	var execCmd *exec.Cmd
+	if e.cfg.NoSecurity {
+		execCmd = exec.CommandContext(ctx, runCmd[0], runCmd[1:]...)
 		execCmd.Env = execEnv
+		execCmd.Dir = e.workDir
+		// 用户程序及其子进程位于独立的进程组，超时时可以整组终止
+		execCmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	} else {
+		secProfile := security.ProfileForLanguage(e.cfg.Language)
+		secProfile.MemoryLimitBytes = e.cfg.DefaultExecuteMemoryLimit
+		secProfile.StackLimitBytes = e.cfg.StackLimit
+		secProfile.FileSizeLimitBytes = e.cfg.FileSizeLimit
+		secProfile.OpenFilesLimit = e.cfg.OpenFilesLimit
+		if e.cfg.ProcessLimit > 0 {
+			secProfile.PidsLimit = e.cfg.ProcessLimit
+		}
+		// 内核在超出CPU时间限制后终止进程，最终结果仍以实际测得的CPU时间判定
+		if e.cfg.DefaultExecuteTimeLimit > 0 {
+			secProfile.CPUTimeLimitSec = int(math.Ceil(e.cfg.DefaultExecuteTimeLimit.Seconds())) + 1
+		}
+
+		var err error
+		sb, err = security.NewSandbox(secProfile)
+		// 执行结束时只清理本次执行的资源
+		defer sb.Close()
+		if err != nil {
+			// 没有cgroup时内存和进程数限制不生效，不能启动用户程序
+			util.ErrorLog("应用安全限制失败: %v", err)
+			closeAll(pio.closeAfterStart)
+			return NewResult(StatusSandboxError, fmt.Errorf("failed to set up sandbox: %w", err))
+		}
+		sb.CPU = e.cpu
+		sb.WorkDir = e.workDir
+		sb.UID, sb.GID = e.uid, e.gid
+
+		execCmd, err = sb.Command(ctx, runCmd[0], runCmd[1:], execEnv)
+		if err != nil {
+			closeAll(pio.closeAfterStart)
+			return NewResult(StatusSandboxError, fmt.Errorf("failed to prepare sandboxed command: %w", err))
+		}
 	}
 
 	// Setup stdin if provided
-	if stdinData != nil {
+	if file, ok := pio.stdin.(*os.File); ok {
+		execCmd.Stdin = file
+	} else if pio.stdin != nil {
 		stdinPipe, err := execCmd.StdinPipe()
 		if err != nil {
+			closeAll(pio.closeAfterStart)
 			return NewResult(StatusSandboxError, fmt.Errorf("failed to get stdin pipe: %w", err))
 		}
 		go func() {
 			defer stdinPipe.Close()
-			_, err := io.WriteString(stdinPipe, *stdinData)
+			_, err := io.Copy(stdinPipe, pio.stdin)
 			if err != nil {
 				log.Printf("Error writing to stdin pipe: %v", err)
 			}
@@ -64,111 +151,183 @@ func (e *Executor) Execute(ctx context.Context, runCmd []string, env map[string]
 
 	// Setup stdout/stderr with limits
 	var stdoutBuf, stderrBuf bytes.Buffer
-	stdoutWriter := NewLimitedWriter(&stdoutBuf, e.cfg.MaxStdoutSize)
-	stderrWriter := NewLimitedWriter(&stderrBuf, e.cfg.MaxStderrSize)
-	execCmd.Stdout = stdoutWriter
+	var stdoutDst, stderrDst io.Writer = &stdoutBuf, &stderrBuf
+	if e.cfg.OutputSink != nil {
+		// 实时输出与捕获的输出经过同一个 LimitedWriter，受相同的大小限制
+		stdoutDst = io.MultiWriter(&stdoutBuf, sinkWriter{sink: e.cfg.OutputSink, stream: StreamStdout})
+		stderrDst = io.MultiWriter(&stderrBuf, sinkWriter{sink: e.cfg.OutputSink, stream: StreamStderr})
+	}
+	stdoutWriter := NewLimitedWriter(stdoutDst, e.cfg.MaxStdoutSize)
+	stderrWriter := NewLimitedWriter(stderrDst, e.cfg.MaxStderrSize)
+	if pio.stdout != nil {
+		execCmd.Stdout = pio.stdout
+	} else {
+		execCmd.Stdout = stdoutWriter
+	}
 	execCmd.Stderr = stderrWriter
 
-	// 直接使用配置中的超时设置，不再处理
-	execTimeout := e.cfg.DefaultExecuteTimeLimit
-	
+	// 超时、超内存或Context取消时终止整个进程组，并通过cgroup终止脱离进程组的后代进程
+	killTree := func() error {
+		err := util.KillProcessGroup(execCmd.Process.Pid)
+		if kerr := sb.Kill(); kerr != nil {
+			err = kerr
+		}
+		return err
+	}
+	execCmd.Cancel = killTree
+	// 后台进程可能继承并一直持有输出管道，主进程退出后最多再等待 processWaitDelay
+	execCmd.WaitDelay = processWaitDelay
+
+	// DefaultExecuteTimeLimit 是CPU时间限制，墙钟时间限制只用于防止程序睡眠或阻塞
+	cpuLimit := e.cfg.DefaultExecuteTimeLimit
+
 	// 确保有合理的默认值
-	if execTimeout <= 0 {
-		execTimeout = 3 * time.Second
-		util.WarnLog("超时设置为0或负值，使用默认值 %.2f秒", execTimeout.Seconds())
+	if cpuLimit <= 0 {
+		cpuLimit = 3 * time.Second
+		util.WarnLog("超时设置为0或负值，使用默认值 %.2f秒", cpuLimit.Seconds())
+	}
+	// 未设置墙钟时间限制时按CPU时间限制推算；设置得比CPU时间限制更短时提高到CPU时间限制
+	wallLimit := e.cfg.WallTimeLimit
+	if wallLimit <= 0 {
+		wallLimit = cpuLimit * DefaultWallTimeFactor
+	} else if wallLimit < cpuLimit {
+		wallLimit = cpuLimit
 	}
-	
+
 	// 仅在调试模式下打印超时设置
-	util.DebugLog("Executor: 使用超时设置: %.2f秒", execTimeout.Seconds())
-	
+	util.DebugLog("Executor: CPU时间限制 %.2f秒, 墙钟时间限制 %.2f秒", cpuLimit.Seconds(), wallLimit.Seconds())
+
+	// 记录运行前工作目录中的文件，用于判断程序写入的文件是否超过大小限制
+	var workDirFiles util.FileSnapshot
+	if e.cfg.FileSizeLimit > 0 && e.workDir != "" {
+		workDirFiles = util.SnapshotFiles(e.workDir)
+	}
+
 	// Execute the command
 	startTime := time.Now()
-	
+
 	// 启动命令但不等待它完成
-	if err := execCmd.Start(); err != nil {
+	err := execCmd.Start()
+	closeAll(pio.closeAfterStart)
+	if err != nil {
 		return NewResult(StatusSandboxError, fmt.Errorf("failed to start command: %w", err))
 	}
 
 	// 获取进程ID并开始监控资源使用
 	pid := execCmd.Process.Pid
+	if sb == nil && e.cpu >= 0 {
+		if err := util.SetCPUAffinity(pid, e.cpu); err != nil {
+			util.WarnLog("%v", err)
+		} else {
+			util.DebugLog("进程 %d 已绑定到CPU %d", pid, e.cpu)
+		}
+	}
 	memLimitKB := e.cfg.DefaultExecuteMemoryLimit / 1024 // 从bytes转换为KB
-	
+	// 进程在cgroup中时由内核强制内存限制，轮询只作为没有cgroup时的后备
+	cgroup := sb.MemoryCgroup()
+	pollLimitKB := memLimitKB
+	if cgroup != nil {
+		pollLimitKB = 0
+	}
+
 	// 内存和时间限制信息只需简要展示
-	util.InfoLog("监控进程 %d: 内存限制 %.2f MB, 时间限制 %.2f 秒", 
-		pid, float64(memLimitKB)/1024, execTimeout.Seconds())
-	
-	// 创建安全配置文件
-	secProfile := security.ProfileForLanguage(e.cfg.Language)
-	
-	// 设置内存限制
-	secProfile.MemoryLimitBytes = e.cfg.DefaultExecuteMemoryLimit
-	
-	// 应用安全限制和资源隔离
-	if err := security.SetupSecurity(secProfile, pid, ""); err != nil {
-		util.WarnLog("应用安全限制失败: %v", err)
-	} else {
-		util.DebugLog("已应用Linux安全限制")
-	}
-	
-	// 注册执行结束时的清理函数
-	defer security.Cleanup()
-	
-	// 创建监控通道
-	monitorDone := make(chan struct{})
-	defer close(monitorDone)
-	
-	// 创建结果通道，用于从监控goroutine接收资源使用情况
-	resultChan := make(chan *util.ProcessStats, 1)
-	
-	// 启动监控goroutine
-	go func() {
-		// 每10ms检查一次资源使用，提高精度
-		procStats := util.MonitorProcess(pid, memLimitKB, execTimeout, 10*time.Millisecond, monitorDone)
-		resultChan <- procStats
-	}()
-	
+	util.InfoLog("监控进程 %d: 内存限制 %.2f MB, CPU时间限制 %.2f 秒, 墙钟时间限制 %.2f 秒",
+		pid, float64(memLimitKB)/1024, cpuLimit.Seconds(), wallLimit.Seconds())
+
+	// 每10ms检查一次资源使用，提高精度
+	monitor := util.NewProcessMonitor(pid, pollLimitKB, wallLimit, 10*time.Millisecond, killTree)
+	monitor.Start()
+
 	// 等待命令完成或超时
 	runErr := execCmd.Wait()
 	duration := time.Since(startTime)
-	
-	// 收集监控结果
-	var procStats *util.ProcessStats
-	select {
-	case stats := <-resultChan:
-		procStats = stats
-	case <-time.After(100 * time.Millisecond):
-		// 如果监控没有及时返回结果，创建默认结果
-		procStats = &util.ProcessStats{
-			PID:       pid,
-			MemoryKB:  -1,
-			Duration:  duration,
-			IsTimeout: false,
+	if errors.Is(runErr, exec.ErrWaitDelay) {
+		// 程序本身已正常退出，残留的后台进程由 killTree 清理
+		runErr = nil
+	}
+	// 进程已被回收，监控器停止后返回的统计数据不会再变化
+	procStats := monitor.Stop()
+	// 不允许任何进程在本次执行结束后继续运行
+	if err := killTree(); err != nil {
+		util.WarnLog("终止残留进程失败: %v", err)
+	}
+	// 用户程序的等待状态：使用PID命名空间时由沙箱的init进程报告
+	waitStatus, hasStatus := sb.ExitStatus()
+	if !hasStatus && execCmd.ProcessState != nil {
+		waitStatus, hasStatus = execCmd.ProcessState.Sys().(syscall.WaitStatus)
+	}
+
+	// cgroup记录的峰值包含所有子孙进程，且不会错过采样间隔之间的峰值
+	memoryKB := procStats.MemoryKB
+	oomKilled := false
+	if cgroup != nil {
+		if peak, err := cgroup.PeakMemoryBytes(); err == nil {
+			memoryKB = peak / 1024
+		} else {
+			util.WarnLog("%v", err)
+		}
+		if oomKilled, err = cgroup.OOMKilled(); err != nil {
+			util.WarnLog("%v", err)
 		}
 	}
 
+	// CPU时间：cgroup统计包含所有子孙进程，否则使用 wait4 返回的rusage（包含已回收的子进程）
+	cpuTime := time.Duration(-1)
+	if cgroup := sb.CPUCgroup(); cgroup != nil {
+		if usage, err := cgroup.CPUUsage(); err == nil {
+			cpuTime = usage
+		} else {
+			util.WarnLog("%v", err)
+		}
+	}
+	if cpuTime < 0 && execCmd.ProcessState != nil {
+		cpuTime = execCmd.ProcessState.UserTime() + execCmd.ProcessState.SystemTime()
+	}
+
 	// 构建结果
 	result := Result{
 		ExitCode:       0, // Will be set below if available
 		TimeUsedMillis: duration.Milliseconds(),
-		MemoryUsedKB:   procStats.MemoryKB,
+		CPUTimeMillis:  cpuTime.Milliseconds(),
+		MemoryUsedKB:   memoryKB,
 		Stdout:         stdoutBuf.String(),
 		Stderr:         stderrBuf.String(),
 	}
+	if hasStatus && waitStatus.Signaled() {
+		result.Signal = signalName(waitStatus.Signal())
+	}
 
 	// 确定状态
+	// 0. 初始化助手未能启动用户程序
+	if sb != nil && execCmd.ProcessState != nil && execCmd.ProcessState.ExitCode() == security.InitFailureExitCode &&
+		strings.HasPrefix(result.Stderr, security.InitErrorPrefix) {
+		result.Status = StatusSandboxError
+		result.Error = fmt.Sprintf("failed to start sandboxed process: %s", strings.TrimSpace(strings.TrimPrefix(result.Stderr, security.InitErrorPrefix)))
+		result.Stderr = ""
+		result.ExitCode = -1
+		return result
+	}
+
 	// 1. 首先检查是否超时
+	if cpuTime > cpuLimit {
+		result.Status = StatusTimeLimitExceeded
+		result.Error = fmt.Sprintf("CPU时间超限: %.2f秒 (限制: %.2f秒)",
+			cpuTime.Seconds(), cpuLimit.Seconds())
+		result.ExitCode = -1
+		return result
+	}
 	if procStats.IsTimeout {
 		result.Status = StatusTimeLimitExceeded
-		result.Error = fmt.Sprintf("时间超限: %.2f秒 (限制: %.2f秒)", 
-			procStats.Duration.Seconds(), execTimeout.Seconds())
+		result.Error = fmt.Sprintf("墙钟时间超限: %.2f秒 (限制: %.2f秒)",
+			procStats.Duration.Seconds(), wallLimit.Seconds())
 		result.ExitCode = -1
 		return result
 	}
-	
+
 	// 2. 再检查内存限制
-	if procStats.IsExceeded {
+	if oomKilled || procStats.IsExceeded {
 		result.Status = StatusMemoryLimitExceeded
-		result.Error = fmt.Sprintf("Memory limit exceeded: %d KB (limit: %d KB)", procStats.MemoryKB, memLimitKB)
+		result.Error = fmt.Sprintf("Memory limit exceeded: %d KB (limit: %d KB)", memoryKB, memLimitKB)
 		result.ExitCode = -1
 		return result
 	}
@@ -195,16 +354,44 @@ func (e *Executor) Execute(ctx context.Context, runCmd []string, env map[string]
 		}
 	}
 
+	// 写入的文件超过 RLIMIT_FSIZE 时进程被SIGXFSZ终止；没有应用rlimit时（禁用安全限制）
+	// 检查本次运行创建或修改的文件是否超过上限，运行前已存在的可执行文件、源码和输入文件不计入
+	fileSizeExceeded := hasStatus && waitStatus.Signaled() && waitStatus.Signal() == syscall.SIGXFSZ
+	if !fileSizeExceeded && workDirFiles != nil {
+		fileSizeExceeded = workDirFiles.ChangedFileLargerThan(e.workDir, e.cfg.FileSizeLimit)
+	}
+	if fileSizeExceeded {
+		errAppend := fmt.Errorf("%w (file size, limit: %d bytes)", ErrOutputLimitExceeded, e.cfg.FileSizeLimit)
+		if outputLimitErr != nil {
+			outputLimitErr = fmt.Errorf("%v; %v", outputLimitErr, errAppend)
+		} else {
+			outputLimitErr = errAppend
+		}
+	}
+
 	if outputLimitErr != nil {
 		result.Status = StatusOutputLimitExceeded
 		result.Error = outputLimitErr.Error()
 	}
 
 	// 5. Check run errors and exit code
-	if execCmd.ProcessState != nil {
+	if hasStatus {
+		result.ExitCode = waitStatus.ExitStatus() // -1 when killed by a signal
+	} else if execCmd.ProcessState != nil {
 		result.ExitCode = execCmd.ProcessState.ExitCode()
 	}
 
+	if hasStatus && waitStatus.Signaled() && result.Status == "" {
+		sig := waitStatus.Signal()
+		if sig == syscall.SIGSYS && sb != nil {
+			// seccomp以SIGSYS终止调用了被禁止的系统调用的进程
+			result.Status = StatusRestrictedFunction
+			result.Error = "Restricted function: the program made a forbidden system call (SIGSYS)"
+		} else {
+			result.Status = runtimeErrorStatus(sig)
+			result.Error = fmt.Sprintf("Runtime error: killed by signal %s (%v)", result.Signal, sig)
+		}
+	}
 	if runErr != nil && result.Status == "" {
 		result.Status = StatusRuntimeError
 		result.Error = fmt.Sprintf("Runtime error: %v (exit code: %d)", runErr, result.ExitCode)
@@ -223,6 +410,103 @@ func (e *Executor) Execute(ctx context.Context, runCmd []string, env map[string]
 	return result
 }
 
+// ExecuteInteractive runs runCmd alongside a peer process (an interactor) with the stdout of
+// each wired to the stdin of the other. The peer runs on the peer executor. If transcript is non-nil,
+// everything exchanged is recorded into it, user output prefixed with "> " and peer output with "< ".
+// It returns the results of the user program and the peer, in that order.
+func (e *Executor) ExecuteInteractive(ctx context.Context, runCmd []string, env map[string]string,
+	peer *Executor, peerCmd []string, peerEnv map[string]string, transcript io.Writer) (Result, Result) {
+	// userOut -> peerIn, peerOut -> userIn
+	peerIn, userOut, err := os.Pipe()
+	if err != nil {
+		res := NewResult(StatusSandboxError, fmt.Errorf("failed to create interaction pipe: %w", err))
+		return res, res
+	}
+	userIn, peerOut, err := os.Pipe()
+	if err != nil {
+		peerIn.Close()
+		userOut.Close()
+		res := NewResult(StatusSandboxError, fmt.Errorf("failed to create interaction pipe: %w", err))
+		return res, res
+	}
+
+	// The parent keeps its copy of userIn open until both sides finish, so an interactor writing to a
+	// user program that already exited fills the pipe buffer instead of dying from SIGPIPE
+	defer userIn.Close()
+	userIO := processIO{stdin: userIn}
+	peerIO := processIO{stdin: peerIn, closeAfterStart: []io.Closer{peerIn}}
+	if transcript != nil {
+		// Copy through the transcript; the write ends are only released once the copy finishes
+		tlog := &interactionLog{w: transcript}
+		userIO.stdout = io.MultiWriter(tlog.side("> "), userOut)
+		userIO.closeAfterWait = []io.Closer{userOut}
+		peerIO.stdout = io.MultiWriter(tlog.side("< "), peerOut)
+		peerIO.closeAfterWait = []io.Closer{peerOut}
+	} else {
+		userIO.stdout = userOut
+		userIO.closeAfterStart = append(userIO.closeAfterStart, userOut)
+		peerIO.stdout = peerOut
+		peerIO.closeAfterStart = append(peerIO.closeAfterStart, peerOut)
+	}
+
+	var peerResult Result
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		peerResult = peer.run(ctx, peerCmd, peerEnv, peerIO)
+	}()
+	userResult := e.run(ctx, runCmd, env, userIO)
+	wg.Wait()
+
+	return userResult, peerResult
+}
+
+// interactionLog serializes the two directions of an interaction into one transcript.
+type interactionLog struct {
+	mu      sync.Mutex
+	w       io.Writer
+	last    string // prefix of the side that wrote last
+	midLine bool   // whether the last write ended without a newline
+}
+
+// side returns a writer recording data sent by one side, each line prefixed by prefix.
+func (l *interactionLog) side(prefix string) io.Writer {
+	return &prefixWriter{log: l, prefix: prefix}
+}
+
+// prefixWriter records one direction of an interaction.
+type prefixWriter struct {
+	log    *interactionLog
+	prefix string
+}
+
+func (pw *prefixWriter) Write(p []byte) (int, error) {
+	l := pw.log
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.midLine && l.last != pw.prefix {
+		// The other side interrupted a partial line
+		io.WriteString(l.w, "\n")
+		l.midLine = false
+	}
+	for _, line := range strings.SplitAfter(string(p), "\n") {
+		if line == "" {
+			continue
+		}
+		if !l.midLine {
+			line = pw.prefix + line
+		}
+		if _, err := io.WriteString(l.w, line); err != nil {
+			return 0, err
+		}
+		l.midLine = !strings.HasSuffix(line, "\n")
+	}
+	l.last = pw.prefix
+	return len(p), nil
+}
+
 // --- LimitedWriter ---
 
 // LimitedWriter wraps an io.Writer but stops writing after a certain limit.
@@ -245,7 +529,7 @@ func (lw *LimitedWriter) Write(p []byte) (n int, err error) {
 
 	remaining := lw.limit - lw.written
 	if remaining <= 0 {
-		if (!lw.Exceeded) {
+		if !lw.Exceeded {
 			lw.Exceeded = true
 		}
 		return len(p), nil // Pretend we wrote everything
@@ -265,4 +549,4 @@ func (lw *LimitedWriter) Write(p []byte) (n int, err error) {
 	}
 
 	return n, err
-}
\ No newline at end of file
+}
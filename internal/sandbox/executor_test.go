@@ -0,0 +1,274 @@
+// internal/sandbox/executor_test.go
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CodeRushOJ/croj-sandbox/internal/security"
+)
+
+// requireSandbox skips the test unless executions can run under the full sandbox, which needs
+// root and a writable cgroup hierarchy.
+func requireSandbox(t *testing.T) {
+	t.Helper()
+	if os.Geteuid() != 0 {
+		t.Skip("sandbox tests must run as root")
+	}
+	sb, err := security.NewSandbox(security.NewDefaultSecurityProfile())
+	defer sb.Close()
+	if err != nil || sb.Cgroup == nil {
+		t.Skipf("cgroups unavailable: %v", err)
+	}
+}
+
+// testExecutor returns an executor for cfg running in a fresh work directory.
+func testExecutor(t *testing.T, cfg Config) *Executor {
+	t.Helper()
+	executor := NewExecutor(cfg)
+	executor.workDir = t.TempDir()
+	return executor
+}
+
+// testConfig returns the default config with a short CPU time limit.
+func testConfig() Config {
+	cfg := DefaultConfig()
+	cfg.DefaultExecuteTimeLimit = 500 * time.Millisecond
+	return cfg
+}
+
+// blockingConfig returns cfg with a stdin that never delivers data, so "read" blocks until the
+// wall-clock limit without using CPU time.
+func blockingConfig(t *testing.T, cfg Config) Config {
+	t.Helper()
+	stdin, w := io.Pipe()
+	t.Cleanup(func() { w.Close() })
+	cfg.StdinReader = stdin
+	return cfg
+}
+
+// sandboxCgroups returns the cgroup directories this process currently has under the croj cgroup root,
+// for both cgroup v2 (/sys/fs/cgroup/croj) and v1 (one croj directory per controller).
+func sandboxCgroups(t *testing.T) []string {
+	t.Helper()
+	pattern := fmt.Sprintf("croj_sandbox_%d_*", os.Getpid())
+	var dirs []string
+	for _, root := range []string{"/sys/fs/cgroup/croj", "/sys/fs/cgroup/*/croj"} {
+		matches, err := filepath.Glob(filepath.Join(root, pattern))
+		if err != nil {
+			t.Fatalf("glob %s: %v", root, err)
+		}
+		dirs = append(dirs, matches...)
+	}
+	return dirs
+}
+
+// captureLog redirects the standard logger into a buffer for the rest of the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer // the standard logger serializes its writes
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &buf
+}
+
+func TestExecuteParallelRemovesCgroups(t *testing.T) {
+	requireSandbox(t)
+	before := sandboxCgroups(t)
+	logs := captureLog(t)
+
+	const runs = 16
+	results := make([]Result, runs)
+	var wg sync.WaitGroup
+	for i := 0; i < runs; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// 一半正常退出，一半超时被终止（只用shell内建命令，seccomp禁止再次execve）
+			cfg, script := DefaultConfig(), fmt.Sprintf("echo %d", i)
+			if i%2 == 1 {
+				cfg, script = blockingConfig(t, testConfig()), "read line"
+			}
+			results[i] = testExecutor(t, cfg).Execute(context.Background(), []string{"/bin/sh", "-c", script}, nil, nil)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, res := range results {
+		want := StatusAccepted
+		if i%2 == 1 {
+			want = StatusTimeLimitExceeded
+		}
+		if res.Status != want {
+			t.Errorf("run %d: status %s (%s), want %s", i, res.Status, res.Error, want)
+		}
+		if want == StatusAccepted && strings.TrimSpace(res.Stdout) != fmt.Sprint(i) {
+			t.Errorf("run %d: stdout %q", i, res.Stdout)
+		}
+	}
+	if after := sandboxCgroups(t); len(after) != len(before) {
+		t.Errorf("%d cgroups before the runs, %d after; leftovers: %v", len(before), len(after), after)
+	}
+	if strings.Contains(logs.String(), "清理cgroup") {
+		t.Errorf("cgroup removal failed:\n%s", logs.String())
+	}
+}
+
+// forkerSource double-forks grandchildren that start their own session, so they are neither
+// children of the program nor in its process group, and then spins until the time limit.
+const forkerSource = `#include <unistd.h>
+int main(void) {
+	for (int i = 0; i < 4; i++) {
+		if (fork() == 0) {
+			if (fork() == 0) {
+				setsid();
+				for (;;) sleep(1000);
+			}
+			_exit(0);
+		}
+	}
+	for (;;) {}
+}
+`
+
+// processesRunning returns the PIDs of host processes whose command line starts with path.
+func processesRunning(t *testing.T, path string) []int {
+	t.Helper()
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		t.Fatalf("read /proc: %v", err)
+	}
+	var pids []int
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		cmdline, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "cmdline"))
+		if err == nil && strings.HasPrefix(string(cmdline), path+"\x00") {
+			pids = append(pids, pid)
+		}
+	}
+	return pids
+}
+
+func TestExecuteTimeoutKillsDetachedDescendants(t *testing.T) {
+	requireSandbox(t)
+	gcc, err := exec.LookPath("gcc")
+	if err != nil {
+		t.Skip("gcc not found")
+	}
+	executor := testExecutor(t, testConfig())
+	src := filepath.Join(executor.workDir, "forker.c")
+	exe := filepath.Join(executor.workDir, "forker")
+	if err := os.WriteFile(src, []byte(forkerSource), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command(gcc, "-o", exe, src).CombinedOutput(); err != nil {
+		t.Fatalf("compile forker: %v\n%s", err, out)
+	}
+	before := sandboxCgroups(t)
+
+	res := executor.Execute(context.Background(), []string{exe}, nil, nil)
+	if res.Status != StatusTimeLimitExceeded {
+		t.Fatalf("status %s (%s), want %s", res.Status, res.Error, StatusTimeLimitExceeded)
+	}
+	// 进程组和cgroup中的进程在 Execute 返回前已全部终止，cgroup目录随之删除
+	if pids := processesRunning(t, exe); len(pids) > 0 {
+		t.Errorf("processes survived the time limit: %v", pids)
+	}
+	if after := sandboxCgroups(t); len(after) != len(before) {
+		t.Errorf("cgroup not removed, processes may remain in it: %v", after)
+	}
+}
+
+func TestExecuteConcurrentNoSecurity(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.NoSecurity = true
+
+	const runs = 12
+	results := make([]Result, runs)
+	var wg sync.WaitGroup
+	for i := 0; i < runs; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// 每三个执行中有一个超时，监控器在其他执行进行中终止它
+			runCfg, script := cfg, fmt.Sprintf("echo %d; echo err%d >&2", i, i)
+			if i%3 == 2 {
+				runCfg, script = blockingConfig(t, testConfig()), "read line"
+				runCfg.NoSecurity = true
+			}
+			results[i] = testExecutor(t, runCfg).Execute(context.Background(), []string{"/bin/sh", "-c", script}, nil, nil)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, res := range results {
+		if i%3 == 2 {
+			if res.Status != StatusTimeLimitExceeded {
+				t.Errorf("run %d: status %s (%s), want %s", i, res.Status, res.Error, StatusTimeLimitExceeded)
+			}
+			continue
+		}
+		if res.Status != StatusAccepted || strings.TrimSpace(res.Stdout) != fmt.Sprint(i) || strings.TrimSpace(res.Stderr) != fmt.Sprintf("err%d", i) {
+			t.Errorf("run %d: status %s, stdout %q, stderr %q", i, res.Status, res.Stdout, res.Stderr)
+		}
+		// 最终快照在进程回收之后取得
+		if res.TimeUsedMillis < 0 || res.CPUTimeMillis < 0 {
+			t.Errorf("run %d: missing final stats: time %dms, CPU %dms", i, res.TimeUsedMillis, res.CPUTimeMillis)
+		}
+	}
+}
+
+func TestExecuteFileSizeLimitIgnoresExistingFiles(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.NoSecurity = true
+	cfg.FileSizeLimit = 16
+
+	for _, tc := range []struct {
+		size int
+		want Status
+	}{
+		{16, StatusAccepted},
+		{17, StatusOutputLimitExceeded},
+	} {
+		executor := testExecutor(t, cfg)
+		// 运行前已存在的大文件（如编译产物）不算作程序的输出
+		if err := os.WriteFile(filepath.Join(executor.workDir, "main"), make([]byte, 1024), 0755); err != nil {
+			t.Fatal(err)
+		}
+		script := fmt.Sprintf("printf '%%0%dd' 0 > out.txt", tc.size)
+		res := executor.Execute(context.Background(), []string{"/bin/sh", "-c", script}, nil, nil)
+		if res.Status != tc.want {
+			t.Errorf("writing %d bytes: status %s (%s), want %s", tc.size, res.Status, res.Error, tc.want)
+		}
+	}
+}
+
+func TestExecuteShortWallLimitRaisedToCPULimit(t *testing.T) {
+	cfg := blockingConfig(t, DefaultConfig())
+	cfg.NoSecurity = true
+	cfg.DefaultExecuteTimeLimit = time.Second
+	cfg.WallTimeLimit = 100 * time.Millisecond
+
+	// 墙钟时间限制比CPU时间限制短时提高到CPU时间限制，而不是CPU时间限制的 DefaultWallTimeFactor 倍
+	res := testExecutor(t, cfg).Execute(context.Background(), []string{"/bin/sh", "-c", "read line"}, nil, nil)
+	if res.Status != StatusTimeLimitExceeded {
+		t.Fatalf("status %s (%s), want %s", res.Status, res.Error, StatusTimeLimitExceeded)
+	}
+	if used := time.Duration(res.TimeUsedMillis) * time.Millisecond; used < time.Second || used >= 2*time.Second {
+		t.Errorf("killed after %v, want the 1s CPU limit as the wall-clock limit", used)
+	}
+}
@@ -0,0 +1,143 @@
+// internal/sandbox/fileio.go
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// ErrInvalidFileName is returned when a requested input or output file name is not a plain file name.
+var ErrInvalidFileName = errors.New("invalid file name")
+
+// validateFileName checks that name is a plain file name inside the work dir that does not
+// clash with the program's own source, executable or other build files.
+func validateFileName(name string, prog *compiledProgram) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\\x00") {
+		return fmt.Errorf("%w: %q", ErrInvalidFileName, name)
+	}
+	if name == filepath.Base(prog.srcPath) || name == filepath.Base(prog.exePath) || prog.buildFiles[name] {
+		return fmt.Errorf("%w: %q is reserved for the program", ErrInvalidFileName, name)
+	}
+	return nil
+}
+
+// outputFileNames returns the names of all files to collect after a run, sorted.
+func outputFileNames(cfg Config) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, name := range cfg.OutputFiles {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for name := range cfg.ExpectedOutputFiles {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// prepareRunFiles removes everything a previous run of the same program left in its work dir,
+// keeping only the build, and writes the input files of this run.
+func prepareRunFiles(prog *compiledProgram, cfg Config) error {
+	entries, err := os.ReadDir(prog.runDir)
+	if err != nil {
+		return fmt.Errorf("failed to read work dir: %w", err)
+	}
+	for _, entry := range entries {
+		if prog.buildFiles[entry.Name()] {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(prog.runDir, entry.Name())); err != nil {
+			return fmt.Errorf("failed to remove %s left by a previous run: %w", entry.Name(), err)
+		}
+	}
+	for _, name := range outputFileNames(cfg) {
+		if err := validateFileName(name, prog); err != nil {
+			return err
+		}
+	}
+	for name, content := range cfg.InputFiles {
+		if err := validateFileName(name, prog); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(prog.runDir, name), []byte(content), 0644); err != nil {
+			return fmt.Errorf("failed to write input file %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// dirEntryNames returns the names of the entries directly inside dir.
+func dirEntryNames(dir string) (map[string]bool, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+	names := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		names[entry.Name()] = true
+	}
+	return names, nil
+}
+
+// errNotRegular marks an output file that is not a regular file.
+var errNotRegular = errors.New("not a regular file")
+
+// collectOutputFiles reads the named output files from dir. Missing files, and anything that is
+// not a regular file, are left out of the returned map, so a program cannot make the sandbox
+// follow a symlink or block on a FIFO. exceeded is the name of the first file larger than limit bytes, if any.
+func collectOutputFiles(dir string, names []string, limit int64) (files map[string]string, exceeded string, err error) {
+	files = make(map[string]string)
+	for _, name := range names {
+		f, err := os.OpenFile(filepath.Join(dir, name), os.O_RDONLY|syscall.O_NOFOLLOW|syscall.O_NONBLOCK, 0)
+		if os.IsNotExist(err) || errors.Is(err, syscall.ELOOP) {
+			continue
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to open output file %s: %w", name, err)
+		}
+		content, exceededFile, err := readOutputFile(f, limit)
+		f.Close()
+		if errors.Is(err, errNotRegular) {
+			continue
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read output file %s: %w", name, err)
+		}
+		files[name] = content
+		if exceededFile && exceeded == "" {
+			exceeded = name
+		}
+	}
+	return files, exceeded, nil
+}
+
+// readOutputFile reads at most limit bytes of a regular file, reporting whether it was larger.
+func readOutputFile(f *os.File, limit int64) (string, bool, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return "", false, err
+	}
+	if !info.Mode().IsRegular() {
+		return "", false, errNotRegular
+	}
+	data, err := io.ReadAll(io.LimitReader(f, limit+1))
+	if err != nil {
+		return "", false, err
+	}
+	if int64(len(data)) > limit {
+		return string(data[:limit]), true, nil
+	}
+	return string(data), false, nil
+}
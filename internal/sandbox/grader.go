@@ -0,0 +1,85 @@
+// internal/sandbox/grader.go
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// GraderSpec attaches trusted, problem-provided files to a submission, e.g. the grader.cpp and
+// solution.h of a "implement this function" problem or a Java Main that calls the user's Solution.
+// The files are copied into the run dir before compiling and are never returned in responses.
+type GraderSpec struct {
+	Files          map[string]string `json:"files"`          // Relative path -> content
+	SrcName        string            `json:"srcName"`        // File name for the user's code (default: the language's SrcName)
+	CompileCommand string            `json:"compileCommand"` // Compile command template (default: the language's); may use {{GRADER_FILES}}
+}
+
+// graderFiles validates the grader files of cfg against the user's submission. A grader file may
+// not replace the user's source file or any submitted file.
+func graderFiles(cfg Config, srcName string, submitted map[string]string) (map[string]string, error) {
+	if cfg.Grader == nil {
+		return nil, nil
+	}
+	files := make(map[string]string, len(cfg.Grader.Files))
+	for name, content := range cfg.Grader.Files {
+		cleaned, err := cleanSourcePath(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := submitted[cleaned]; ok || cleaned == srcName {
+			return nil, fmt.Errorf("%w: %s is provided by the grader", ErrInvalidSubmission, cleaned)
+		}
+		files[cleaned] = content
+	}
+	return files, nil
+}
+
+// graderSources returns the value of {{GRADER_FILES}}: the paths below dir of the grader files
+// that have the same extension as the user's source file, i.e. the ones the compiler must be
+// given explicitly. Headers and other files are only placed in the run dir.
+func graderSources(dir string, files map[string]string, srcName string) string {
+	ext := path.Ext(srcName)
+	var paths []string
+	for name := range files {
+		if ext != "" && path.Ext(name) == ext {
+			paths = append(paths, filepath.Join(dir, filepath.FromSlash(name)))
+		}
+	}
+	sort.Strings(paths)
+	return strings.Join(paths, " ")
+}
+
+// removeGraderFiles deletes the grader sources from the run dir once the program is built, so the
+// user's program cannot read them back while it runs.
+func removeGraderFiles(dir string, files map[string]string) error {
+	for name := range files {
+		if err := os.Remove(filepath.Join(dir, filepath.FromSlash(name))); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove grader file %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// cacheKeyFiles combines everything besides the source code that determines a build into one map
+// for ArtifactKey. Grader files and the source file name use keys that no valid path can have.
+func cacheKeyFiles(submitted, grader map[string]string, srcName string) map[string]string {
+	if len(grader) == 0 && srcName == "" {
+		return submitted
+	}
+	keyFiles := make(map[string]string, len(submitted)+len(grader)+1)
+	for name, content := range submitted {
+		keyFiles[name] = content
+	}
+	for name, content := range grader {
+		keyFiles["\x00grader/"+name] = content
+	}
+	if srcName != "" {
+		keyFiles["\x00src"] = srcName
+	}
+	return keyFiles
+}
@@ -0,0 +1,41 @@
+// internal/sandbox/grader_test.go
+package sandbox
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGraderFiles(t *testing.T) {
+	submitted := map[string]string{"main.cpp": "", "lib/util.h": ""}
+	tests := []struct {
+		name    string
+		files   map[string]string
+		srcName string
+		ok      bool
+	}{
+		{name: "separate files", files: map[string]string{"grader.cpp": "", "lib/grader.h": ""}, srcName: "main.cpp", ok: true},
+		{name: "user's source", files: map[string]string{"main.cpp": ""}, srcName: "main.cpp"},
+		{name: "user's source in another form", files: map[string]string{"./main.cpp": ""}, srcName: "main.cpp"},
+		{name: "renamed user's source", files: map[string]string{"solution.cpp": ""}, srcName: "solution.cpp"},
+		{name: "submitted file", files: map[string]string{"lib/util.h": ""}, srcName: "main.cpp"},
+		{name: "submitted file in another form", files: map[string]string{"lib//./util.h": ""}, srcName: "main.cpp"},
+		{name: "parent dir", files: map[string]string{"../grader.cpp": ""}, srcName: "main.cpp"},
+		{name: "absolute path", files: map[string]string{"/tmp/grader.cpp": ""}, srcName: "main.cpp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{Grader: &GraderSpec{Files: tt.files}}
+			files, err := graderFiles(cfg, tt.srcName, submitted)
+			if !tt.ok {
+				if !errors.Is(err, ErrInvalidSubmission) {
+					t.Errorf("got %v, %v; want ErrInvalidSubmission", files, err)
+				}
+				return
+			}
+			if err != nil || len(files) != len(tt.files) {
+				t.Errorf("got %v, %v; want %d files", files, err, len(tt.files))
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+// internal/sandbox/interactor.go
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/CodeRushOJ/croj-sandbox/internal/util"
+)
+
+// InteractorSpec configures interactive judging: a trusted interactor program runs alongside
+// the submission with their stdin/stdout cross-wired, and its exit code decides the verdict.
+// The interactor is invoked testlib-style as "interactor <input> <output> <answer>".
+type InteractorSpec struct {
+	Language         string `json:"language"`         // Language of SourceCode (default: cpp)
+	SourceCode       string `json:"sourceCode"`       // Interactor program source
+	RecordTranscript bool   `json:"recordTranscript"` // Return the full exchange in the result
+}
+
+// interactor is a compiled interactor program ready to judge runs.
+type interactor struct {
+	runner           *Runner
+	prog             *compiledProgram
+	cfg              Config
+	recordTranscript bool
+}
+
+// newInteractor compiles the interactor described by spec. The returned cleanup (if non-nil)
+// removes its build directory.
+func (r *Runner) newInteractor(ctx context.Context, spec *InteractorSpec, cfg Config) (*interactor, func(), error) {
+	if spec.SourceCode == "" {
+		return nil, nil, fmt.Errorf("%w: interactor has no source code", ErrInteractorFailed)
+	}
+	language := spec.Language
+	if language == "" {
+		language = "cpp"
+	}
+	interactorCfg := cfg
+	interactorCfg.Checker = nil
+	interactorCfg.Interactor = nil
+	interactorCfg.SourceFiles = nil
+	interactorCfg.SourceArchive = nil
+	interactorCfg.Grader = nil
+	interactorCfg.OutputSink = nil
+	interactorCfg.StdinReader = nil
+	interactorCfg.Language = language
+	interactorCfg.DefaultExecuteMemoryLimit = int64(DefaultMemoryLimitMB) * 1024 * 1024
+
+	prog, cleanup, failed := r.compile(ctx, language, spec.SourceCode, interactorCfg)
+	if failed != nil {
+		if cleanup != nil {
+			cleanup()
+		}
+		return nil, nil, fmt.Errorf("%w: %s: %s", ErrInteractorFailed, failed.Status, failed.Error)
+	}
+	return &interactor{runner: r, prog: prog, cfg: interactorCfg, recordTranscript: spec.RecordTranscript}, cleanup, nil
+}
+
+// run executes the user command against the interactor and decides the verdict.
+// userTimeout is the user program's CPU time limit; the interactor gets that plus DefaultCheckerTimeLimitSec,
+// and likewise for the wall-clock limit.
+func (it *interactor) run(ctx context.Context, executor *Executor, runCmd []string, env map[string]string,
+	userTimeout time.Duration, input, answer string) Result {
+	ioDir, err := os.MkdirTemp(it.prog.runDir, "interact-")
+	if err != nil {
+		return NewResult(StatusSandboxError, fmt.Errorf("failed to create interactor dir: %w", err))
+	}
+	defer os.RemoveAll(ioDir)
+
+	inputPath := filepath.Join(ioDir, "input.txt")
+	outputPath := filepath.Join(ioDir, "output.txt")
+	answerPath := filepath.Join(ioDir, "answer.txt")
+	if err := os.WriteFile(inputPath, []byte(input), 0644); err != nil {
+		return NewResult(StatusSandboxError, fmt.Errorf("failed to write interactor input: %w", err))
+	}
+	if err := os.WriteFile(answerPath, []byte(answer), 0644); err != nil {
+		return NewResult(StatusSandboxError, fmt.Errorf("failed to write interactor answer: %w", err))
+	}
+
+	peerCmd, err := it.runner.runCommand(it.prog, it.cfg.DefaultExecuteMemoryLimit/1024)
+	if err != nil {
+		return NewResult(StatusSandboxError, err)
+	}
+	peerCmd = append(peerCmd, inputPath, outputPath, answerPath)
+
+	peerCfg := it.cfg
+	peerCfg.DefaultExecuteTimeLimit = userTimeout + time.Duration(DefaultCheckerTimeLimitSec)*time.Second
+	// 交互器大部分时间在等待用户程序，墙钟时间必须比用户程序更长
+	peerCfg.WallTimeLimit = executor.cfg.WallTimeLimit + time.Duration(DefaultCheckerTimeLimitSec)*time.Second
+
+	var transcript bytes.Buffer
+	var transcriptWriter io.Writer
+	if it.recordTranscript {
+		transcriptWriter = NewLimitedWriter(&transcript, it.cfg.MaxStdoutSize)
+	}
+	peer := NewExecutor(peerCfg)
+	peer.workDir = it.prog.runDir
+	userRes, peerRes := executor.ExecuteInteractive(ctx, runCmd, env, peer, peerCmd, it.prog.langCfg.Run.Env, transcriptWriter)
+
+	result := decideInteractive(userRes, peerRes)
+	result.Transcript = transcript.String()
+	util.DebugLog("交互评测: 用户程序 %s, 交互器退出码 %d, 结果 %s", userRes.Status, peerRes.ExitCode, result.Status)
+	return result
+}
+
+// decideInteractive combines the user's and interactor's results into the final verdict.
+// Resource limit verdicts of the user program win; otherwise the interactor's exit code decides,
+// so a user program killed by a broken pipe after a rejected answer is reported as Wrong Answer.
+func decideInteractive(userRes, peerRes Result) Result {
+	result := userRes
+	result.Stdout = "" // consumed by the interactor
+	result.CheckerMessage = strings.TrimSpace(peerRes.Stderr)
+
+	switch userRes.Status {
+	case StatusTimeLimitExceeded, StatusMemoryLimitExceeded, StatusOutputLimitExceeded, StatusRestrictedFunction, StatusSandboxError:
+		return result
+	}
+
+	switch {
+	case peerRes.Status == StatusTimeLimitExceeded || peerRes.Status == StatusMemoryLimitExceeded || peerRes.Status == StatusSandboxError:
+		result.Status = StatusSandboxError
+		result.Error = fmt.Sprintf("%v: %s: %s", ErrInteractorFailed, peerRes.Status, peerRes.Error)
+	case checkerRejected(peerRes.ExitCode):
+		result.Status = StatusWrongAnswer
+		result.Error = ErrOutputMismatch.Error()
+		result.PartialScore = checkerPoints(peerRes.ExitCode, result.CheckerMessage)
+	case peerRes.ExitCode != checkerExitOK:
+		result.Status = StatusSandboxError
+		result.Error = fmt.Sprintf("%v (exit code %d): %s", ErrInteractorFailed, peerRes.ExitCode, result.CheckerMessage)
+	}
+	// Interactor accepted: keep the user program's own status (Accepted or Runtime Error)
+	return result
+}
@@ -15,8 +15,10 @@ func ConfigureDefaultLanguages(cfg *Config) {
 	cfg.Languages["go"] = LanguageConfig{
 		Compile: CompileConfig{
 			SrcName:        "main.go",
-			ExeName:        "main", 
-			CompileCommand: "go build -ldflags \"-s -w\" -o {{EXE_PATH}} {{SRC_PATH}}",
+			ExeName:        "main",
+			CompileCommand: "go build -ldflags \"-s -w\" -o {{EXE_PATH}} {{SRC_PATH}} {{GRADER_FILES}}",
+			// 多文件提交按包编译，没有 go.mod 时自动创建
+			ProjectCommand: "test -f go.mod || go mod init submission >/dev/null 2>&1; go build -ldflags \"-s -w\" -o {{EXE_PATH}} .",
 			TimeoutSec:     DefaultCompileTimeLimitSec,
 		},
 		Run: RunConfig{
@@ -32,7 +34,8 @@ func ConfigureDefaultLanguages(cfg *Config) {
 		Compile: CompileConfig{
 			SrcName:        "main.cpp",
 			ExeName:        "main",
-			CompileCommand: "g++ -Wall -O2 -std=c++17 {{SRC_PATH}} -o {{EXE_PATH}}",
+			CompileCommand: "g++ -Wall -O2 -std=c++17 {{SRC_PATH}} {{GRADER_FILES}} -o {{EXE_PATH}}",
+			ProjectCommand: "g++ -Wall -O2 -std=c++17 -I{{WORK_DIR}} $(find {{WORK_DIR}} -name '*.cpp') -o {{EXE_PATH}}",
 			TimeoutSec:     DefaultCompileTimeLimitSec,
 		},
 		Run: RunConfig{
@@ -40,14 +43,15 @@ func ConfigureDefaultLanguages(cfg *Config) {
 			Env:        make(map[string]string),
 			TimeoutSec: DefaultExecuteTimeLimitSec,
 			MemoryMB:   DefaultMemoryLimitMB,
+			StackMB:    -1, // 深度递归只受内存限制约束
 		},
 	}
 
 	// Python 3
 	cfg.Languages["python"] = LanguageConfig{
 		Compile: CompileConfig{
-			SrcName:        "main.py",
-			ExeName:        "main.py", // 不编译，直接运行
+			SrcName: "main.py",
+			ExeName: "main.py", // 不编译，直接运行
 		},
 		Run: RunConfig{
 			Command:    "python3 {{SRC_PATH}}",
@@ -61,8 +65,9 @@ func ConfigureDefaultLanguages(cfg *Config) {
 	cfg.Languages["java"] = LanguageConfig{
 		Compile: CompileConfig{
 			SrcName:        "Main.java",
-			ExeName:        "Main.class", 
-			CompileCommand: "javac {{SRC_PATH}}",
+			ExeName:        "Main.class",
+			CompileCommand: "javac {{SRC_PATH}} {{GRADER_FILES}}",
+			ProjectCommand: "javac -d {{WORK_DIR}} $(find {{WORK_DIR}} -name '*.java')",
 			TimeoutSec:     DefaultCompileTimeLimitSec,
 		},
 		Run: RunConfig{
@@ -76,8 +81,8 @@ func ConfigureDefaultLanguages(cfg *Config) {
 	// JavaScript (Node.js)
 	cfg.Languages["javascript"] = LanguageConfig{
 		Compile: CompileConfig{
-			SrcName:        "main.js",
-			ExeName:        "main.js", // 不编译，直接运行
+			SrcName: "main.js",
+			ExeName: "main.js", // 不编译，直接运行
 		},
 		Run: RunConfig{
 			Command:    "node {{SRC_PATH}}",
@@ -100,4 +105,4 @@ func GetGoPath() string {
 		}
 	}
 	return gopath
-}
\ No newline at end of file
+}
@@ -0,0 +1,126 @@
+// internal/sandbox/limiter.go
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SlotPool bounds how many operations of one kind run at once. Callers queue in Acquire
+// until a slot frees up or their context is cancelled. A nil *SlotPool imposes no limit.
+type SlotPool struct {
+	name  string
+	slots chan int
+
+	mu        sync.Mutex
+	waiting   int
+	acquired  int64
+	totalWait time.Duration
+	maxWait   time.Duration
+}
+
+// SlotPoolStats is a snapshot of a pool's usage and wait-time metrics.
+type SlotPoolStats struct {
+	Capacity      int   `json:"capacity"`      // Number of slots (0 = unlimited)
+	InUse         int   `json:"inUse"`         // Slots currently held
+	Waiting       int   `json:"waiting"`       // Callers currently queued
+	Acquired      int64 `json:"acquired"`      // Total successful acquisitions
+	TotalWaitMs   int64 `json:"totalWaitMs"`   // Sum of wait times in milliseconds
+	AverageWaitMs int64 `json:"averageWaitMs"` // Mean wait time in milliseconds
+	MaxWaitMs     int64 `json:"maxWaitMs"`     // Longest wait in milliseconds
+}
+
+// NewSlotPool creates a pool of size slots numbered 0..size-1. It returns nil (unlimited) if size <= 0.
+func NewSlotPool(name string, size int) *SlotPool {
+	if size <= 0 {
+		return nil
+	}
+	p := &SlotPool{name: name, slots: make(chan int, size)}
+	for i := 0; i < size; i++ {
+		p.slots <- i
+	}
+	return p
+}
+
+// Acquire waits for a free slot and returns its index, or -1 for an unlimited pool.
+// It fails if ctx is done before a slot becomes available.
+func (p *SlotPool) Acquire(ctx context.Context) (int, error) {
+	if p == nil {
+		return -1, nil
+	}
+
+	// Fast path, no wait to account for
+	select {
+	case slot := <-p.slots:
+		p.record(0)
+		return slot, nil
+	default:
+	}
+
+	p.mu.Lock()
+	p.waiting++
+	p.mu.Unlock()
+	start := time.Now()
+
+	select {
+	case slot := <-p.slots:
+		p.mu.Lock()
+		p.waiting--
+		p.mu.Unlock()
+		p.record(time.Since(start))
+		return slot, nil
+	case <-ctx.Done():
+		p.mu.Lock()
+		p.waiting--
+		p.mu.Unlock()
+		return -1, fmt.Errorf("%w: waited %v for %s slot: %w", ErrSlotUnavailable, time.Since(start).Round(time.Millisecond), p.name, ctx.Err())
+	}
+}
+
+// Release returns a slot obtained from Acquire.
+func (p *SlotPool) Release(slot int) {
+	if p == nil || slot < 0 {
+		return
+	}
+	p.slots <- slot
+}
+
+func (p *SlotPool) record(wait time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.acquired++
+	p.totalWait += wait
+	if wait > p.maxWait {
+		p.maxWait = wait
+	}
+}
+
+// Stats returns the pool's current metrics.
+func (p *SlotPool) Stats() SlotPoolStats {
+	if p == nil {
+		return SlotPoolStats{}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := SlotPoolStats{
+		Capacity:    cap(p.slots),
+		InUse:       cap(p.slots) - len(p.slots),
+		Waiting:     p.waiting,
+		Acquired:    p.acquired,
+		TotalWaitMs: p.totalWait.Milliseconds(),
+		MaxWaitMs:   p.maxWait.Milliseconds(),
+	}
+	if p.acquired > 0 {
+		stats.AverageWaitMs = stats.TotalWaitMs / p.acquired
+	}
+	return stats
+}
+
+// ConcurrencyStats reports the compile, execution and session slot pools of a runner.
+type ConcurrencyStats struct {
+	Compile SlotPoolStats `json:"compile"`
+	Execute SlotPoolStats `json:"execute"`
+	Session SlotPoolStats `json:"session"`
+}
@@ -0,0 +1,88 @@
+// internal/sandbox/policy.go
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Run policies selectable through Config.RunPolicy for multi-case judging
+const (
+	RunAll           = "run-all"         // Run every case (default)
+	RunStopOnFailure = "stop-on-failure" // Skip all remaining cases after the first non-accepted one (ICPC style)
+	RunSamplesFirst  = "samples-first"   // Run sample cases first and skip the rest if any of them fails
+)
+
+// ErrInvalidRunPolicy is returned for an unknown run policy.
+var ErrInvalidRunPolicy = errors.New("invalid run policy")
+
+// validateRunPolicy checks that policy is empty or one of the Run* constants.
+func validateRunPolicy(policy string) error {
+	switch policy {
+	case "", RunAll, RunStopOnFailure, RunSamplesFirst:
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrInvalidRunPolicy, policy)
+}
+
+// caseScheduler runs test cases on demand under a run policy. Each case is run at most once;
+// once the policy stops judging, every case not yet run is reported as Skipped.
+type caseScheduler struct {
+	policy  string
+	cases   []TestCase
+	run     func(i int) Result
+	results []Result
+	ran     []bool
+	stopped string // 非空时为停止评测的原因，之后的用例都标记为 Skipped
+}
+
+func newCaseScheduler(policy string, cases []TestCase, run func(i int) Result) *caseScheduler {
+	return &caseScheduler{
+		policy:  policy,
+		cases:   cases,
+		run:     run,
+		results: make([]Result, len(cases)),
+		ran:     make([]bool, len(cases)),
+	}
+}
+
+// start runs the cases the policy requires up front: the samples for RunSamplesFirst.
+func (s *caseScheduler) start() {
+	if s.policy != RunSamplesFirst {
+		return
+	}
+	for i, tc := range s.cases {
+		if !tc.Sample {
+			continue
+		}
+		if res := s.get(i); !res.IsOK() && s.stopped == "" {
+			s.stopped = fmt.Sprintf("not run: sample test case %d failed", i+1)
+		}
+	}
+}
+
+// get returns the result of case i, running it unless judging has stopped.
+func (s *caseScheduler) get(i int) Result {
+	if s.ran[i] {
+		return s.results[i]
+	}
+	var res Result
+	if s.stopped != "" {
+		res = skippedResult(s.stopped, s.cases[i].weight())
+	} else {
+		res = s.run(i)
+		if !res.IsOK() && s.policy == RunStopOnFailure {
+			s.stopped = fmt.Sprintf("not run: stopped after test case %d failed", i+1)
+		}
+	}
+	s.results[i], s.ran[i] = res, true
+	return res
+}
+
+// all returns the results of every case in order.
+func (s *caseScheduler) all() []Result {
+	for i := range s.cases {
+		s.get(i)
+	}
+	return s.results
+}
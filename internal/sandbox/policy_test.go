@@ -0,0 +1,81 @@
+// internal/sandbox/policy_test.go
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCaseScheduler(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  string
+		failed  []int // 不通过的用例
+		samples []int // 样例用例
+		wantRun []int // 按运行顺序排列的用例，其余用例应为 Skipped
+	}{
+		{name: "default runs all", failed: []int{1}, wantRun: []int{0, 1, 2, 3}},
+		{name: "run all", policy: RunAll, failed: []int{0, 2}, wantRun: []int{0, 1, 2, 3}},
+		{name: "stop on failure", policy: RunStopOnFailure, failed: []int{1}, wantRun: []int{0, 1}},
+		{name: "stop on failure all pass", policy: RunStopOnFailure, wantRun: []int{0, 1, 2, 3}},
+		{name: "samples first", policy: RunSamplesFirst, samples: []int{2, 3}, wantRun: []int{2, 3, 0, 1}},
+		{name: "failed sample", policy: RunSamplesFirst, samples: []int{2, 3}, failed: []int{2}, wantRun: []int{2}},
+		{name: "failed non-sample", policy: RunSamplesFirst, samples: []int{2}, failed: []int{0}, wantRun: []int{2, 0, 1, 3}},
+		{name: "samples first ignored by other policies", policy: RunStopOnFailure, samples: []int{3}, failed: []int{0}, wantRun: []int{0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cases := make([]TestCase, 4)
+			for _, i := range tt.samples {
+				cases[i].Sample = true
+			}
+			failed := make(map[int]bool)
+			for _, i := range tt.failed {
+				failed[i] = true
+			}
+			var runs []int
+			s := newCaseScheduler(tt.policy, cases, func(i int) Result {
+				runs = append(runs, i)
+				if failed[i] {
+					return Result{Status: StatusWrongAnswer}
+				}
+				return Result{Status: StatusAccepted}
+			})
+			s.start()
+			results := s.all()
+
+			if fmt.Sprint(runs) != fmt.Sprint(tt.wantRun) {
+				t.Errorf("ran cases %v, want %v", runs, tt.wantRun)
+			}
+			ran := make(map[int]bool)
+			for _, i := range runs {
+				ran[i] = true
+			}
+			for i, res := range results {
+				if !ran[i] && res.Status != StatusSkipped {
+					t.Errorf("case %d was not run but has status %s", i, res.Status)
+				}
+				if ran[i] && res.Status == StatusSkipped {
+					t.Errorf("case %d was run but reported as skipped", i)
+				}
+			}
+			// 已有结果的用例不会再次运行
+			s.get(0)
+			if len(runs) != len(tt.wantRun) {
+				t.Errorf("case 0 was run again: %v", runs)
+			}
+		})
+	}
+}
+
+func TestValidateRunPolicy(t *testing.T) {
+	for _, policy := range []string{"", RunAll, RunStopOnFailure, RunSamplesFirst} {
+		if err := validateRunPolicy(policy); err != nil {
+			t.Errorf("validateRunPolicy(%q) = %v", policy, err)
+		}
+	}
+	if err := validateRunPolicy("first-failure"); !errors.Is(err, ErrInvalidRunPolicy) {
+		t.Errorf("unknown policy: %v, want ErrInvalidRunPolicy", err)
+	}
+}
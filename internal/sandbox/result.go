@@ -1,7 +1,13 @@
 // internal/sandbox/result.go
 package sandbox
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"syscall"
+)
 
 // Status represents the outcome of a sandbox execution.
 type Status string
@@ -11,25 +17,99 @@ const (
 	StatusCompileError        Status = "Compile Error"         // Code failed to compile locally.
 	StatusRuntimeError        Status = "Runtime Error"         // Code compiled but exited with non-zero status locally.
 	StatusTimeLimitExceeded   Status = "Time Limit Exceeded"   // Local execution time exceeded the limit.
-	StatusMemoryLimitExceeded Status = "Memory Limit Exceeded" // Placeholder - Cannot be reliably enforced/detected locally in v0.1.
+	StatusMemoryLimitExceeded Status = "Memory Limit Exceeded" // Detected from cgroup OOM kills, or the memory poller without cgroups
 	StatusOutputLimitExceeded Status = "Output Limit Exceeded" // Stdout or Stderr exceeded the size limit.
 	StatusSandboxError        Status = "Sandbox Error"         // Internal error within the sandbox system (e.g., file ops).
 	StatusUnknown             Status = "Unknown"               // Unknown status.
 	StatusWrongAnswer         Status = "Wrong Answer"          // Output doesn't match expected (used with comparison)
+	StatusRestrictedFunction  Status = "Restricted Function"   // Killed by seccomp (SIGSYS) for calling a forbidden system call.
+	StatusSkipped             Status = "Skipped"               // Test case or subtask not run because an earlier one failed.
+	StatusCancelled           Status = "Cancelled"             // Compilation or execution aborted by cancelling the caller's context.
 )
 
+// signalNames maps the signals a program is commonly killed by to their conventional names.
+var signalNames = map[syscall.Signal]string{
+	syscall.SIGABRT: "SIGABRT",
+	syscall.SIGALRM: "SIGALRM",
+	syscall.SIGBUS:  "SIGBUS",
+	syscall.SIGFPE:  "SIGFPE",
+	syscall.SIGHUP:  "SIGHUP",
+	syscall.SIGILL:  "SIGILL",
+	syscall.SIGINT:  "SIGINT",
+	syscall.SIGKILL: "SIGKILL",
+	syscall.SIGPIPE: "SIGPIPE",
+	syscall.SIGQUIT: "SIGQUIT",
+	syscall.SIGSEGV: "SIGSEGV",
+	syscall.SIGSYS:  "SIGSYS",
+	syscall.SIGTERM: "SIGTERM",
+	syscall.SIGTRAP: "SIGTRAP",
+	syscall.SIGUSR1: "SIGUSR1",
+	syscall.SIGUSR2: "SIGUSR2",
+	syscall.SIGXCPU: "SIGXCPU",
+	syscall.SIGXFSZ: "SIGXFSZ",
+}
+
+// signalName returns the conventional name of sig, e.g. "SIGSEGV".
+func signalName(sig syscall.Signal) string {
+	if name, ok := signalNames[sig]; ok {
+		return name
+	}
+	return fmt.Sprintf("SIG%d", int(sig))
+}
+
+// runtimeErrorStatus returns the Runtime Error sub-status for a program killed by sig,
+// e.g. "Runtime Error (SIGSEGV)".
+func runtimeErrorStatus(sig syscall.Signal) Status {
+	return Status(fmt.Sprintf("%s (%s)", StatusRuntimeError, signalName(sig)))
+}
+
+// IsRuntimeError reports whether s is Runtime Error or one of its signal sub-statuses.
+func (s Status) IsRuntimeError() bool {
+	return s == StatusRuntimeError || strings.HasPrefix(string(s), string(StatusRuntimeError)+" (")
+}
+
 // Result holds the outcome of a code execution in the sandbox.
 type Result struct {
-	Status         Status // Final status of the execution.
-	ExitCode       int    // Exit code of the user's program (-1 if not run or error before exec).
-	Stdout         string // Standard output from the user's program execution (potentially truncated).
-	Stderr         string // Standard error from the user's program execution (potentially truncated).
-	Error          string // Internal sandbox error message OR compile error output.
-	TimeUsedMillis int64  // Time taken by the user's program execution in milliseconds (-1 if not run or TLE).
-	MemoryUsedKB   int64  // Memory usage in Kilobytes (-1 in v0.1 - not measured locally).
+	Status         Status            // Final status of the execution.
+	ExitCode       int               // Exit code of the user's program (-1 if not run or error before exec).
+	Stdout         string            // Standard output from the user's program execution (potentially truncated).
+	Stderr         string            // Standard error from the user's program execution (potentially truncated).
+	OutputFiles    map[string]string // Contents of the collected output files (file I/O problems), keyed by name.
+	Error          string            // Internal sandbox error message OR compile error output.
+	TimeUsedMillis int64             // Wall-clock time taken by the user's program execution in milliseconds (-1 if not run).
+	CPUTimeMillis  int64             // CPU (user+sys) time of the program and its descendants in milliseconds (-1 if not run).
+	MemoryUsedKB   int64             // Memory usage in Kilobytes (-1 in v0.1 - not measured locally).
+	Signal         string            // Signal that terminated the program, e.g. "SIGSEGV" (empty if it exited normally or did not run).
 
 	// Compile specific info
 	CompileOutput string // Full output from the compilation phase (stderr).
+	CacheHit      bool   // True if the compile phase was skipped thanks to the artifact cache.
+
+	// Checker info
+	CheckerMessage string // Message reported by the output checker or interactor (empty if no comparison was made).
+	Transcript     string // Interactive runs: recorded exchange ("> " user, "< " interactor), if requested.
+
+	// Multi-testcase info
+	Score           int             // Score earned (sum over cases, or the case weight scaled by how much of this case passed).
+	PartialScore    float64         // Share (0..1) of the case's score the checker awarded a partially correct answer (Wrong Answer only).
+	TotalScore      int             // Maximum attainable score.
+	TestCaseResults []Result        // Per-case results when judging multiple test cases (nil for single runs).
+	SubtaskResults  []SubtaskResult // Per-subtask score breakdown when the request defines subtasks.
+}
+
+// scoreRatio returns the share of a case's score earned by this result: 1 if accepted,
+// otherwise the partial score awarded by the checker.
+func (r *Result) scoreRatio() float64 {
+	if r.IsOK() {
+		return 1
+	}
+	return r.PartialScore
+}
+
+// scaledScore returns the whole points of total earned at ratio, rounded down.
+// The small epsilon keeps ratios such as 0.3 from losing a point to floating-point error.
+func scaledScore(total int, ratio float64) int {
+	return int(math.Floor(float64(total)*ratio + 1e-9))
 }
 
 // IsOK checks if the result status indicates successful compilation and execution within limits.
@@ -43,6 +123,7 @@ func NewResult(status Status, err error) Result {
 		Status:         status,
 		ExitCode:       -1, // Default
 		TimeUsedMillis: -1,
+		CPUTimeMillis:  -1,
 		MemoryUsedKB:   -1, // Mark as not measured
 	}
 	if err != nil {
@@ -53,11 +134,17 @@ func NewResult(status Status, err error) Result {
 
 // Predefined sandbox errors (can be expanded)
 var (
-	ErrCompileTimeout     = errors.New("local compilation timed out")
-	ErrCompileFailed      = errors.New("local compilation failed")
-	ErrExecuteTimeout     = errors.New("local execution timed out")
-	ErrHostTempDir        = errors.New("failed to manage host temporary directory")
-	ErrBinaryNotFound     = errors.New("compiled binary not found")
+	ErrCompileTimeout      = errors.New("local compilation timed out")
+	ErrCompileFailed       = errors.New("local compilation failed")
+	ErrExecuteTimeout      = errors.New("local execution timed out")
+	ErrHostTempDir         = errors.New("failed to manage host temporary directory")
+	ErrBinaryNotFound      = errors.New("compiled binary not found")
 	ErrOutputLimitExceeded = errors.New("output limit exceeded")
-	ErrOutputMismatch     = errors.New("output does not match expected")
-)
\ No newline at end of file
+	ErrOutputMismatch      = errors.New("output does not match expected")
+	ErrNoTestCases         = errors.New("no test cases provided")
+	ErrCheckerFailed       = errors.New("output checker failed")
+	ErrInteractorFailed    = errors.New("interactor failed")
+	ErrSlotUnavailable     = errors.New("no sandbox slot available")
+	ErrCancelled           = errors.New("execution cancelled")
+	ErrInvalidConfig       = errors.New("invalid sandbox configuration")
+)
@@ -6,11 +6,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/CodeRushOJ/croj-sandbox/internal/util" // Import util which now includes compare
@@ -20,18 +24,51 @@ import (
 type Runner struct {
 	cfg      Config
 	executor *Executor
+	cache    *ArtifactCache // nil when the compile cache is disabled
+
+	compileSlots *SlotPool // bounds concurrent compiles (nil = unlimited)
+	execSlots    *SlotPool // bounds concurrent executions (nil = unlimited)
+	sessionSlots *SlotPool // bounds concurrent session programs, separate from execSlots (nil = unlimited)
 }
 
+// executeGracePeriod is added to a run's time limit for the backup context deadline.
+const executeGracePeriod = 5 * time.Second
+
 // NewRunner creates a new local sandbox runner instance.
 func NewRunner(cfg Config) (*Runner, error) {
 	if err := util.EnsureDir(cfg.HostTempDir); err != nil {
 		return nil, fmt.Errorf("%w: %w", ErrHostTempDir, err)
 	}
 	executor := NewExecutor(cfg)
-	log.Printf("Local sandbox runner initialized: HostTemp='%s'", cfg.HostTempDir)
+	var cache *ArtifactCache
+	if cfg.CompileCacheMaxBytes > 0 {
+		var err error
+		cache, err = NewArtifactCache(filepath.Join(cfg.HostTempDir, compileCacheDirName), cfg.CompileCacheMaxBytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrHostTempDir, err)
+		}
+	}
+	if cfg.RunUIDBase > 0 && (cfg.NoSecurity || os.Geteuid() != 0) {
+		util.WarnLog("未以root运行或已禁用安全限制，用户程序将不会切换到UID %d", cfg.RunUIDBase)
+		cfg.RunUIDBase = 0
+	}
+	maxExecutions := cfg.MaxParallelExecutions
+	if maxExecutions <= 0 || (len(cfg.ExecutionCPUs) > 0 && maxExecutions > len(cfg.ExecutionCPUs)) {
+		maxExecutions = len(cfg.ExecutionCPUs)
+	}
+	if cfg.RunUIDBase > 0 && (maxExecutions <= 0 || cfg.MaxParallelSessions <= 0) {
+		// 每个并发执行必须独占一个UID，执行数不受限时无法保证
+		return nil, fmt.Errorf("%w: runUIDBase requires a bounded number of parallel executions and sessions", ErrInvalidConfig)
+	}
+	log.Printf("Local sandbox runner initialized: HostTemp='%s', CompileCache=%d bytes, MaxCompiles=%d, MaxExecutions=%d, MaxSessions=%d, CPUs=%v, RunUIDBase=%d",
+		cfg.HostTempDir, cfg.CompileCacheMaxBytes, cfg.MaxParallelCompiles, maxExecutions, cfg.MaxParallelSessions, cfg.ExecutionCPUs, cfg.RunUIDBase)
 	return &Runner{
-		cfg:      cfg,
-		executor: executor,
+		cfg:          cfg,
+		executor:     executor,
+		cache:        cache,
+		compileSlots: NewSlotPool("compile", cfg.MaxParallelCompiles),
+		execSlots:    NewSlotPool("execute", maxExecutions),
+		sessionSlots: NewSlotPool("session", cfg.MaxParallelSessions),
 	}, nil
 }
 
@@ -41,49 +78,238 @@ func (r *Runner) Run(ctx context.Context, language, sourceCode string, stdinData
 }
 
 // RunWithConfig 使用自定义配置运行代码
-func (r *Runner) RunWithConfig(ctx context.Context, language, sourceCode string, stdinData *string, expectedOutput *string, cfg Config) Result {
-	util.DebugLog("Runner: 执行超时设置: %.2f秒, 用户指定: %v", 
+func (r *Runner) RunWithConfig(ctx context.Context, language, sourceCode string, stdinData *string, expectedOutput *string, cfg Config) (result Result) {
+	defer markCancelled(ctx, &result)
+	cfg.Language = language // 用户程序使用该语言的安全配置
+	util.DebugLog("Runner: 执行超时设置: %.2f秒, 用户指定: %v",
 		cfg.DefaultExecuteTimeLimit.Seconds(), cfg.UserSpecifiedTimeout)
-	
+
+	prog, cleanup, failed := r.compile(ctx, language, sourceCode, cfg)
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if failed != nil {
+		return *failed
+	}
+
+	j, judgeCleanup, err := r.newJudge(ctx, cfg, expectedOutput != nil || len(cfg.ExpectedOutputFiles) > 0)
+	if judgeCleanup != nil {
+		defer judgeCleanup()
+	}
+	if err != nil {
+		util.ErrorLog("[%s] 准备评测程序失败: %v", language, err)
+		res := NewResult(StatusSandboxError, err)
+		res.CompileOutput = prog.compileOutput
+		return res
+	}
+
+	execResult := r.execute(ctx, prog, stdinData, expectedOutput, cfg, j)
+	util.InfoLog("[%s] 最终执行结果: %s", language, execResult.Status)
+	return execResult
+}
+
+// RunTestCases compiles the source once and runs it against every test case using the runner's config.
+func (r *Runner) RunTestCases(ctx context.Context, language, sourceCode string, testCases []TestCase) Result {
+	return r.RunTestCasesWithConfig(ctx, language, sourceCode, testCases, r.cfg)
+}
+
+// RunTestCasesWithConfig compiles the source once and then runs the binary against each test case,
+// applying per-case limits on top of cfg. The returned Result carries the aggregate verdict and
+// the per-case results in TestCaseResults.
+func (r *Runner) RunTestCasesWithConfig(ctx context.Context, language, sourceCode string, testCases []TestCase, cfg Config) (result Result) {
+	defer markCancelled(ctx, &result)
+	cfg.Language = language // 用户程序使用该语言的安全配置
+	if len(testCases) == 0 {
+		return NewResult(StatusSandboxError, ErrNoTestCases)
+	}
+	if err := validateSubtasks(cfg.Subtasks, len(testCases)); err != nil {
+		return NewResult(StatusSandboxError, err)
+	}
+	if err := validateRunPolicy(cfg.RunPolicy); err != nil {
+		return NewResult(StatusSandboxError, err)
+	}
+
+	prog, cleanup, failed := r.compile(ctx, language, sourceCode, cfg)
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if failed != nil {
+		return *failed
+	}
+
+	j, judgeCleanup, err := r.newJudge(ctx, cfg, true)
+	if judgeCleanup != nil {
+		defer judgeCleanup()
+	}
+	if err != nil {
+		util.ErrorLog("[%s] 准备评测程序失败: %v", language, err)
+		res := NewResult(StatusSandboxError, err)
+		res.CompileOutput = prog.compileOutput
+		return res
+	}
+
+	runCase := func(i int) Result {
+		tc := testCases[i]
+		if ctx.Err() != nil {
+			return skippedResult("not run: judging was cancelled", tc.weight())
+		}
+		util.InfoLog("[%s] 运行测试用例 %d/%d", language, i+1, len(testCases))
+		caseCfg := caseConfig(cfg, tc)
+		caseCfg.OutputSink = cfg.OutputSink.withTestCase(i + 1)
+		caseRes := r.execute(ctx, prog, tc.Stdin, tc.ExpectedOutput, caseCfg, j)
+		markCancelled(ctx, &caseRes)
+		caseRes.CompileOutput = ""
+		caseRes.TotalScore = tc.weight()
+		caseRes.Score = scaledScore(caseRes.TotalScore, caseRes.scoreRatio())
+		return caseRes
+	}
+	sched := newCaseScheduler(cfg.RunPolicy, testCases, runCase)
+	sched.start()
+
+	if len(cfg.Subtasks) > 0 {
+		caseResults, subtaskResults := judgeSubtasks(cfg.Subtasks, testCases, sched.get)
+		result = aggregateResults(caseResults)
+		result.SubtaskResults = subtaskResults
+		// 按子任务计分
+		result.Score, result.TotalScore = 0, 0
+		for _, st := range subtaskResults {
+			result.Score += st.Score
+			result.TotalScore += st.TotalScore
+		}
+	} else {
+		result = aggregateResults(sched.all())
+	}
+	result.CompileOutput = prog.compileOutput
+	result.CacheHit = prog.cacheHit
+	util.InfoLog("[%s] 最终执行结果: %s (得分 %d/%d)", language, result.Status, result.Score, result.TotalScore)
+	return result
+}
+
+// markCancelled replaces the verdict of res with Cancelled when ctx was cancelled by the caller:
+// whatever the killed compiler or program reported is then an artifact of the cancellation.
+// Timeouts (context.DeadlineExceeded) keep their verdict.
+func markCancelled(ctx context.Context, res *Result) {
+	if !errors.Is(ctx.Err(), context.Canceled) || res.Status == StatusSkipped {
+		return
+	}
+	res.Status = StatusCancelled
+	res.Error = ErrCancelled.Error()
+	res.ExitCode = -1
+	res.Signal = ""
+	res.Score = 0
+}
+
+// compiledProgram is the outcome of a successful compile phase, shared by every run of the same submission.
+type compiledProgram struct {
+	language      string
+	langCfg       LanguageConfig
+	runDir        string
+	srcPath       string
+	exePath       string
+	compileOutput string
+	cacheHit      bool
+	buildFiles    map[string]bool // top-level entries of runDir after compiling; everything else is removed before each run
+}
+
+// compile sets up the run directory, writes the source and runs the language's compile command.
+// On failure it returns a non-nil Result describing the error. The returned cleanup (if non-nil)
+// must be called by the caller once all runs are finished.
+func (r *Runner) compile(ctx context.Context, language, sourceCode string, cfg Config) (*compiledProgram, func(), *Result) {
+	fail := func(res Result) (*compiledProgram, func(), *Result) {
+		return nil, nil, &res
+	}
+
 	// 1. Get Language Configuration
 	langCfg, ok := cfg.Languages[language]
-	if (!ok) {
+	if !ok {
 		err := fmt.Errorf("language configuration for '%s' not found", language)
 		log.Printf("%v", err)
-		return NewResult(StatusSandboxError, err)
+		return fail(NewResult(StatusSandboxError, err))
 	}
 
 	// 2. Setup temporary directory
 	hostRunDir, cleanup, err := util.SetupHostRunDir(cfg.HostTempDir)
 	if err != nil {
 		util.ErrorLog("创建临时目录失败: %v", err)
-		return NewResult(StatusSandboxError, fmt.Errorf("%w: %w", ErrHostTempDir, err))
+		return fail(NewResult(StatusSandboxError, fmt.Errorf("%w: %w", ErrHostTempDir, err)))
+	}
+	failClean := func(res Result) (*compiledProgram, func(), *Result) {
+		return nil, cleanup, &res
 	}
-	defer cleanup()
 
 	// 3. Determine and write source file
 	srcFileName := langCfg.Compile.SrcName
+	var graderSrcName string
+	if cfg.Grader != nil && cfg.Grader.SrcName != "" {
+		graderSrcName, err = cleanSourcePath(cfg.Grader.SrcName)
+		if err != nil {
+			return failClean(NewResult(StatusSandboxError, err))
+		}
+		srcFileName = graderSrcName
+	}
 	if srcFileName == "" {
-		return NewResult(StatusSandboxError, fmt.Errorf("language '%s' CompileConfig missing SrcName", language))
+		return failClean(NewResult(StatusSandboxError, fmt.Errorf("language '%s' CompileConfig missing SrcName", language)))
+	}
+	sourceFilePath := filepath.Join(hostRunDir, filepath.FromSlash(srcFileName))
+	files, err := submissionFiles(cfg, srcFileName, sourceCode)
+	if err != nil {
+		util.WarnLog("[%s] 多文件提交无效: %v", language, err)
+		return failClean(NewResult(StatusSandboxError, err))
+	}
+	grader, err := graderFiles(cfg, srcFileName, files)
+	if err != nil {
+		util.WarnLog("[%s] 评测附加文件无效: %v", language, err)
+		return failClean(NewResult(StatusSandboxError, err))
+	}
+	if err := writeSubmissionFiles(hostRunDir, grader); err != nil {
+		log.Printf("Error writing grader files to %s: %v", hostRunDir, err)
+		return failClean(NewResult(StatusSandboxError, err))
+	}
+	if err := writeSubmissionFiles(hostRunDir, files); err != nil {
+		log.Printf("Error writing submission files to %s: %v", hostRunDir, err)
+		return failClean(NewResult(StatusSandboxError, fmt.Errorf("%w: %w", ErrInvalidSubmission, err)))
+	}
+	if _, fromFiles := files[srcFileName]; !fromFiles {
+		if err := os.MkdirAll(filepath.Dir(sourceFilePath), 0755); err != nil {
+			return failClean(NewResult(StatusSandboxError, fmt.Errorf("failed to create source directory: %w", err)))
+		}
+		if err := os.WriteFile(sourceFilePath, []byte(sourceCode), 0644); err != nil {
+			log.Printf("Error writing source code to %s: %v", sourceFilePath, err)
+			return failClean(NewResult(StatusSandboxError, fmt.Errorf("failed to write source file: %w", err)))
+		}
 	}
-	sourceFilePath := filepath.Join(hostRunDir, srcFileName)
-	if err := os.WriteFile(sourceFilePath, []byte(sourceCode), 0644); err != nil {
-		log.Printf("Error writing source code to %s: %v", sourceFilePath, err)
-		return NewResult(StatusSandboxError, fmt.Errorf("failed to write source file: %w", err))
+	log.Printf("[%s] Source code saved to: %s (%d extra files, %d grader files)", language, sourceFilePath, len(files), len(grader))
+	compileCommand := langCfg.Compile.CommandFor(len(files) > 0)
+	if cfg.Grader != nil && cfg.Grader.CompileCommand != "" {
+		compileCommand = cfg.Grader.CompileCommand
 	}
-	log.Printf("[%s] Source code saved to: %s", language, sourceFilePath)
 
 	// --- 4. Compile Step ---
 	var compileOutput string
 	var compiledExePath string = sourceFilePath
 	var compileErr error
+	var cacheKey string
+	var cacheHit bool
 
-	if langCfg.Compile.CompileCommand != "" {
+	if compileCommand != "" && r.cache != nil {
+		cacheKey = ArtifactKey(language, compileCommand, sourceCode, cacheKeyFiles(files, grader, graderSrcName))
+		if output, ok := r.cache.Restore(cacheKey, hostRunDir); ok {
+			exeName := langCfg.Compile.ExeName
+			if runtime.GOOS == "windows" && filepath.Ext(exeName) == "" && language != "java" {
+				exeName += ".exe"
+			}
+			compiledExePath = filepath.Join(hostRunDir, exeName)
+			compileOutput = output
+			cacheHit = true
+			log.Printf("[%s] Compile cache hit (%s), skipping compilation phase.", language, cacheKey[:12])
+		}
+	}
+
+	if !cacheHit && compileCommand != "" {
 		log.Printf("[%s] Starting compilation phase.", language)
-		compileStartTime := time.Now()
 		exeName := langCfg.Compile.ExeName
 		if exeName == "" {
-			return NewResult(StatusSandboxError, fmt.Errorf("language '%s' has CompileCommand but no ExeName", language))
+			return failClean(NewResult(StatusSandboxError, fmt.Errorf("language '%s' has CompileCommand but no ExeName", language)))
 		}
 		if runtime.GOOS == "windows" && filepath.Ext(exeName) == "" && language != "java" {
 			exeName += ".exe"
@@ -92,24 +318,40 @@ func (r *Runner) RunWithConfig(ctx context.Context, language, sourceCode string,
 		placeholders := map[string]string{
 			PlaceholderSrcPath: sourceFilePath, PlaceholderExePath: compiledExePath,
 			PlaceholderWorkDir: hostRunDir, PlaceholderExeDir: filepath.Dir(compiledExePath),
+			PlaceholderGraderFiles: graderSources(hostRunDir, grader, srcFileName),
 		}
-		compileCmdStr := util.ProcessCommandString(langCfg.Compile.CompileCommand, placeholders)
+		compileCmdStr := util.ProcessCommandString(compileCommand, placeholders)
 		if compileCmdStr == "" {
-			return NewResult(StatusSandboxError, fmt.Errorf("processed compile command for '%s' is empty", language))
+			return failClean(NewResult(StatusSandboxError, fmt.Errorf("processed compile command for '%s' is empty", language)))
+		}
+		slot, err := r.compileSlots.Acquire(ctx)
+		if err != nil {
+			util.WarnLog("[%s] 等待编译槽位失败: %v", language, err)
+			return failClean(NewResult(StatusSandboxError, err))
 		}
+		compileStartTime := time.Now() // Time spent waiting for a slot is not counted
 		compileTimeout := langCfg.GetCompileTimeout(r.cfg.DefaultCompileTimeLimit)
 		compileCtx, cancel := context.WithTimeout(ctx, compileTimeout)
 		// #nosec G204
 		cmd := exec.CommandContext(compileCtx, "sh", "-c", compileCmdStr)
 		cmd.Dir = hostRunDir
+		// 超时或取消时终止整个编译进程组，而不只是 sh
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		cmd.Cancel = func() error { return util.KillProcessGroup(cmd.Process.Pid) }
+		cmd.WaitDelay = processWaitDelay
 		var stderr, stdout bytes.Buffer
 		cmd.Stderr = &stderr
 		cmd.Stdout = &stdout
+		if cfg.OutputSink != nil {
+			cmd.Stderr = io.MultiWriter(&stderr, sinkWriter{sink: cfg.OutputSink, stream: StreamCompile})
+			cmd.Stdout = io.MultiWriter(&stdout, sinkWriter{sink: cfg.OutputSink, stream: StreamCompile})
+		}
 		log.Printf("[%s] Executing Compile: sh -c \"%s\"", language, compileCmdStr)
 		runCompileErr := cmd.Run()
 		compileOutput = stdout.String() + stderr.String()
 		compileDuration := time.Since(compileStartTime)
 		cancel() // Cancel context
+		r.compileSlots.Release(slot)
 
 		if runCompileErr != nil {
 			if errors.Is(compileCtx.Err(), context.DeadlineExceeded) {
@@ -128,9 +370,14 @@ func (r *Runner) RunWithConfig(ctx context.Context, language, sourceCode string,
 				log.Printf("[%s] %v", language, compileErr)
 			} else {
 				log.Printf("[%s] Compile successful in %v.", language, compileDuration)
+				if cacheKey != "" {
+					if err := r.cache.Store(cacheKey, hostRunDir, compileOutput); err != nil {
+						util.WarnLog("[%s] 写入编译缓存失败: %v", language, err)
+					}
+				}
 			}
 		}
-	} else {
+	} else if !cacheHit {
 		log.Printf("[%s] Skipping compilation phase.", language)
 	}
 
@@ -141,71 +388,364 @@ func (r *Runner) RunWithConfig(ctx context.Context, language, sourceCode string,
 		if !errors.Is(compileErr, ErrCompileTimeout) {
 			res.Error = compileOutput
 		}
-		return res
+		return failClean(res)
+	}
+
+	// 编译型语言的评测附加源码只在编译时需要，运行前删除以免被用户程序读取
+	if compileCommand != "" {
+		if err := removeGraderFiles(hostRunDir, grader); err != nil {
+			return failClean(NewResult(StatusSandboxError, err))
+		}
+	}
+	// 源码和编译产物只有root可写，用户程序不能修改它们，只能在运行目录中新建文件
+	if err := util.ProtectDir(hostRunDir); err != nil {
+		return failClean(NewResult(StatusSandboxError, err))
+	}
+	buildFiles, err := dirEntryNames(hostRunDir)
+	if err != nil {
+		return failClean(NewResult(StatusSandboxError, err))
+	}
+
+	return &compiledProgram{
+		language:      language,
+		langCfg:       langCfg,
+		runDir:        hostRunDir,
+		srcPath:       sourceFilePath,
+		exePath:       compiledExePath,
+		compileOutput: compileOutput,
+		cacheHit:      cacheHit,
+		buildFiles:    buildFiles,
+	}, cleanup, nil
+}
+
+// judge bundles how the output of a run is judged.
+type judge struct {
+	checker    Checker     // compares output with the expected answer
+	interactor *interactor // non-nil for interactive problems; replaces stdin and the checker
+}
+
+// newJudge prepares the checker and interactor requested in cfg, compiling them if needed.
+// The checker is only built when needChecker is set. The returned cleanup (if non-nil) must be
+// called once all runs are finished.
+func (r *Runner) newJudge(ctx context.Context, cfg Config, needChecker bool) (*judge, func(), error) {
+	j := &judge{checker: exactChecker{}}
+	var cleanups []func()
+	cleanup := func() {
+		for _, c := range cleanups {
+			c()
+		}
+	}
+
+	if cfg.Interactor != nil {
+		it, itCleanup, err := r.newInteractor(ctx, cfg.Interactor, cfg)
+		if itCleanup != nil {
+			cleanups = append(cleanups, itCleanup)
+		}
+		if err != nil {
+			return nil, cleanup, err
+		}
+		j.interactor = it
+		return j, cleanup, nil
+	}
+
+	if needChecker {
+		checker, checkerCleanup, err := r.newChecker(ctx, cfg.Checker, cfg)
+		if checkerCleanup != nil {
+			cleanups = append(cleanups, checkerCleanup)
+		}
+		if err != nil {
+			return nil, cleanup, err
+		}
+		j.checker = checker
+	}
+	return j, cleanup, nil
+}
+
+// runCommand expands the language's run command template for a compiled program.
+func (r *Runner) runCommand(prog *compiledProgram, memLimitKB int64) ([]string, error) {
+	runPlaceholders := map[string]string{
+		PlaceholderExePath: prog.exePath, PlaceholderWorkDir: prog.runDir,
+		PlaceholderSrcPath: prog.srcPath, PlaceholderExeDir: filepath.Dir(prog.exePath),
+		PlaceholderMaxMemory: fmt.Sprintf("%d", memLimitKB),
+	}
+	runCmdParts, err := util.ProcessCommandTemplate(prog.langCfg.Run.Command, runPlaceholders)
+	if err != nil {
+		return nil, fmt.Errorf("failed to process run command template for '%s': %w", prog.language, err)
 	}
+	return runCmdParts, nil
+}
+
+// execute runs an already compiled program once with the given stdin and checks its output.
+func (r *Runner) execute(ctx context.Context, prog *compiledProgram, stdinData *string, expectedOutput *string, cfg Config, j *judge) Result {
+	language := prog.language
+	langCfg := prog.langCfg
 
 	// --- 5. Execute Step ---
 	util.InfoLog("[%s] 开始执行阶段", language)
 	memLimitBytes := langCfg.GetMemoryLimit(cfg.DefaultExecuteMemoryLimit)
 	memLimitKB := memLimitBytes / 1024
-	
+
 	// 从语言配置中获取运行时间限制，但考虑用户是否指定了超时
-	timeoutDuration := langCfg.GetExecuteTimeout(cfg.DefaultExecuteTimeLimit, cfg.UserSpecifiedTimeout)
-	util.DebugLog("[%s] 设置时间限制: %.2f秒 (用户指定: %v)", 
-		language, timeoutDuration.Seconds(), cfg.UserSpecifiedTimeout)
-	
+	timeoutDuration := langCfg.GetCPUTimeLimit(cfg)
+	wallTimeLimit := langCfg.GetWallTimeLimit(timeoutDuration, cfg)
+	util.DebugLog("[%s] 设置CPU时间限制: %.2f秒, 墙钟时间限制: %.2f秒 (用户指定: %v)",
+		language, timeoutDuration.Seconds(), wallTimeLimit.Seconds(), cfg.UserSpecifiedTimeout)
+
 	// 处理命令模板
-	runPlaceholders := map[string]string{
-		PlaceholderExePath: compiledExePath, PlaceholderWorkDir: hostRunDir,
-		PlaceholderSrcPath: sourceFilePath, PlaceholderExeDir: filepath.Dir(compiledExePath),
-		PlaceholderMaxMemory: fmt.Sprintf("%d", memLimitKB),
-	}
-	runCmdParts, templateErr := util.ProcessCommandTemplate(langCfg.Run.Command, runPlaceholders)
-	if templateErr != nil {
-		err := fmt.Errorf("failed to process run command template for '%s': %w", language, templateErr)
+	runCmdParts, err := r.runCommand(prog, memLimitKB)
+	if err != nil {
 		log.Printf("%v", err)
 		res := NewResult(StatusSandboxError, err)
-		res.CompileOutput = compileOutput
+		res.CompileOutput = prog.compileOutput
 		return res
 	}
-	
+
 	// 确保超时设置被正确传递到执行器
 	runCfg := cfg
 	runCfg.DefaultExecuteTimeLimit = timeoutDuration
+	runCfg.WallTimeLimit = wallTimeLimit
+	limits := langCfg.GetResourceLimits(cfg)
+	runCfg.StackLimit = limits.StackBytes
+	runCfg.FileSizeLimit = limits.FileSizeBytes
+	runCfg.OpenFilesLimit = limits.OpenFiles
+	runCfg.ProcessLimit = limits.Processes
 	util.DebugLog("[%s] 传递到执行器的超时设置: %.2f seconds", language, runCfg.DefaultExecuteTimeLimit.Seconds())
-	
-	executor := NewExecutor(runCfg)
-	execResult := executor.Execute(ctx, runCmdParts, langCfg.Run.Env, stdinData)
-	execResult.CompileOutput = compileOutput // Add compile output regardless of exec status
 
-	// --- 6. Output Comparison Step ---
-	// Only compare if execution was successful so far (status Accepted) and expected output is provided.
+	// 交互式会话的程序大部分时间在等待输入，使用独立的槽位，不占用判题的执行槽位
+	slots := r.execSlots
+	if cfg.StdinReader != nil {
+		slots = r.sessionSlots
+	}
+	slot, err := slots.Acquire(ctx)
+	if err != nil {
+		util.WarnLog("[%s] 等待执行槽位失败: %v", language, err)
+		res := NewResult(StatusSandboxError, err)
+		res.CompileOutput = prog.compileOutput
+		return res
+	}
+	// 自定义检查器需要单独获取执行槽位，用户程序结束后立即释放本次占用的槽位
+	releaseSlot := sync.OnceFunc(func() { slots.Release(slot) })
+	defer releaseSlot()
+
+	// 备用超时：从获得槽位开始计时，排队时间不计入
+	backupTimeout := wallTimeLimit + executeGracePeriod
+	if j.interactor != nil {
+		backupTimeout += time.Duration(DefaultCheckerTimeLimitSec) * time.Second
+	}
+	runCtx, cancel := context.WithTimeout(ctx, backupTimeout)
+	defer cancel()
+
+	if err := prepareRunFiles(prog, cfg); err != nil {
+		res := NewResult(StatusSandboxError, err)
+		res.CompileOutput = prog.compileOutput
+		return res
+	}
+	var executor *Executor
+	if slots == r.sessionSlots {
+		executor, err = r.credentialExecutor(runCfg, prog.runDir, slot, r.sessionCredentials)
+	} else {
+		executor, err = r.slotExecutor(runCfg, slot, prog.runDir, r.slotCredentials)
+	}
+	if err != nil {
+		res := NewResult(StatusSandboxError, err)
+		res.CompileOutput = prog.compileOutput
+		return res
+	}
+	if j.interactor != nil {
+		var input, answer string
+		if stdinData != nil {
+			input = *stdinData
+		}
+		if expectedOutput != nil {
+			answer = *expectedOutput
+		}
+		util.InfoLog("[%s] 使用交互器运行", language)
+		execResult := j.interactor.run(runCtx, executor, runCmdParts, langCfg.Run.Env, timeoutDuration, input, answer)
+		execResult.CompileOutput = prog.compileOutput
+		execResult.CacheHit = prog.cacheHit
+		return execResult
+	}
+	execResult := executor.Execute(runCtx, runCmdParts, langCfg.Run.Env, stdinData)
+	releaseSlot()
+	execResult.CompileOutput = prog.compileOutput // Add compile output regardless of exec status
+	execResult.CacheHit = prog.cacheHit
+
+	// Collect output files (file I/O problems) once the program has finished
+	if names := outputFileNames(cfg); len(names) > 0 && execResult.Status != StatusSandboxError {
+		limit := cfg.MaxOutputFileSize
+		if limit <= 0 {
+			limit = cfg.MaxStdoutSize
+		}
+		files, exceeded, err := collectOutputFiles(prog.runDir, names, limit)
+		if err != nil {
+			res := NewResult(StatusSandboxError, err)
+			res.CompileOutput = prog.compileOutput
+			return res
+		}
+		execResult.OutputFiles = files
+		if exceeded != "" && execResult.Status == StatusAccepted {
+			execResult.Status = StatusOutputLimitExceeded
+			execResult.Error = fmt.Sprintf("%v (file %s, limit: %d bytes)", ErrOutputLimitExceeded, exceeded, limit)
+		}
+	}
+
+	// --- 6. Output Checking Step ---
+	// Only check if execution was successful so far (status Accepted) and expected output is provided.
+	hasExpected := expectedOutput != nil || len(cfg.ExpectedOutputFiles) > 0
 	if execResult.Status == StatusAccepted && expectedOutput != nil {
-		log.Printf("[%s] Comparing output...", language)
-		match := util.CompareOutputs(execResult.Stdout, *expectedOutput)
-		if !match {
-			log.Printf("[%s] Output mismatch!", language)
-			log.Printf("[%s] Expected: %q", language, util.NormalizeString(*expectedOutput))
-			log.Printf("[%s] Actual: %q", language, util.NormalizeString(execResult.Stdout))
+		log.Printf("[%s] Checking output...", language)
+		var input string
+		if stdinData != nil {
+			input = *stdinData
+		}
+		check := j.checker.Check(ctx, input, execResult.Stdout, *expectedOutput)
+		execResult.CheckerMessage = check.Message
+		switch check.Status {
+		case StatusAccepted:
+			log.Printf("[%s] Output accepted by checker: %s", language, check.Message)
+			// Status remains Accepted
+		case StatusWrongAnswer:
+			log.Printf("[%s] Output mismatch: %s", language, check.Message)
+			util.DebugLog("[%s] Expected: %q", language, util.NormalizeString(*expectedOutput))
+			util.DebugLog("[%s] Actual: %q", language, util.NormalizeString(execResult.Stdout))
 			execResult.Status = StatusWrongAnswer
 			// Add more detail to the error field
 			execResult.Error = ErrOutputMismatch.Error()
-		} else {
-			log.Printf("[%s] Output matches expected.", language)
-			// Status remains Accepted
+			execResult.PartialScore = check.Points
+		default:
+			util.ErrorLog("[%s] 输出检查器错误: %s", language, check.Message)
+			execResult.Status = StatusSandboxError
+			execResult.Error = fmt.Sprintf("%v: %s", ErrCheckerFailed, check.Message)
 		}
-	} else if execResult.Status == StatusAccepted && expectedOutput == nil {
+	} else if execResult.Status == StatusAccepted && !hasExpected {
 		log.Printf("[%s] Skipping output comparison (no expected output provided).", language)
-	} else if expectedOutput != nil {
+	} else if execResult.Status != StatusAccepted && hasExpected {
 		log.Printf("[%s] Skipping output comparison (execution status is %s, not Accepted)", language, execResult.Status)
 	}
+	// Output files are checked once stdout (if expected) was accepted
+	if execResult.Status == StatusAccepted && len(cfg.ExpectedOutputFiles) > 0 {
+		r.checkOutputFiles(ctx, &execResult, checkerInput(stdinData, cfg), cfg.ExpectedOutputFiles, j.checker)
+	}
 
-	util.InfoLog("[%s] 最终执行结果: %s", language, execResult.Status)
 	return execResult
 }
 
+// checkOutputFiles compares each collected output file with its expected content using the checker,
+// in name order, and marks the result Wrong Answer at the first mismatch or missing file.
+func (r *Runner) checkOutputFiles(ctx context.Context, res *Result, input string, expected map[string]string, checker Checker) {
+	names := make([]string, 0, len(expected))
+	for name := range expected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		actual, ok := res.OutputFiles[name]
+		if !ok {
+			res.Status = StatusWrongAnswer
+			res.Error = fmt.Sprintf("%v: output file %s was not created", ErrOutputMismatch, name)
+			return
+		}
+		check := checker.Check(ctx, input, actual, expected[name])
+		switch check.Status {
+		case StatusAccepted:
+			res.CheckerMessage = check.Message
+		case StatusWrongAnswer:
+			res.Status = StatusWrongAnswer
+			res.Error = fmt.Sprintf("%v: %s", ErrOutputMismatch, name)
+			res.CheckerMessage = check.Message
+			res.PartialScore = check.Points
+			return
+		default:
+			res.Status = StatusSandboxError
+			res.Error = fmt.Sprintf("%v: %s", ErrCheckerFailed, check.Message)
+			return
+		}
+	}
+}
+
+// checkerInput returns the input shown to the checker: stdin, or for file I/O problems
+// without stdin, the first input file in name order.
+func checkerInput(stdinData *string, cfg Config) string {
+	if stdinData != nil {
+		return *stdinData
+	}
+	names := make([]string, 0, len(cfg.InputFiles))
+	for name := range cfg.InputFiles {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	sort.Strings(names)
+	return cfg.InputFiles[names[0]]
+}
+
+// slotCredentials returns the UID and GID a user program runs as in an execution slot. NewRunner only
+// enables RunUIDBase with a bounded execute pool, so every concurrent run gets its own IDs.
+func (r *Runner) slotCredentials(slot int) (int, int) {
+	gidBase := r.cfg.RunGIDBase
+	if gidBase <= 0 {
+		gidBase = r.cfg.RunUIDBase
+	}
+	return r.cfg.RunUIDBase + slot, gidBase + slot
+}
+
+// checkerCredentials returns the UID and GID a custom checker runs as in an execution slot. They
+// follow the range of the user programs, so a checker never shares its IDs with a submission.
+func (r *Runner) checkerCredentials(slot int) (int, int) {
+	uid, gid := r.slotCredentials(slot)
+	slots := r.execSlots.Stats().Capacity
+	return uid + slots, gid + slots
+}
+
+// sessionCredentials returns the UID and GID a session program runs as in a session slot. They
+// follow the range of the checkers.
+func (r *Runner) sessionCredentials(slot int) (int, int) {
+	uid, gid := r.slotCredentials(slot)
+	slots := 2 * r.execSlots.Stats().Capacity
+	return uid + slots, gid + slots
+}
+
+// slotExecutor returns an executor for cfg that runs in dir on the CPU pinned to execution slot
+// slot, with the credentials of credentialExecutor.
+func (r *Runner) slotExecutor(cfg Config, slot int, dir string, credentials func(slot int) (int, int)) (*Executor, error) {
+	executor, err := r.credentialExecutor(cfg, dir, slot, credentials)
+	if err != nil {
+		return nil, err
+	}
+	if slot >= 0 && len(r.cfg.ExecutionCPUs) > 0 {
+		executor.cpu = r.cfg.ExecutionCPUs[slot]
+	}
+	return executor, nil
+}
+
+// credentialExecutor returns an executor for cfg that runs in dir. When RunUIDBase is set, the
+// process runs as credentials(slot), which may create files in dir but not modify the root-owned
+// build in it.
+func (r *Runner) credentialExecutor(cfg Config, dir string, slot int, credentials func(slot int) (int, int)) (*Executor, error) {
+	executor := NewExecutor(cfg)
+	executor.workDir = dir
+	if r.cfg.RunUIDBase > 0 {
+		uid, gid := credentials(slot)
+		if err := util.ShareDir(dir, gid); err != nil {
+			return nil, err
+		}
+		executor.uid, executor.gid = uid, gid
+	}
+	return executor, nil
+}
+
+// Stats reports the runner's concurrency slot usage and wait times.
+func (r *Runner) Stats() ConcurrencyStats {
+	return ConcurrencyStats{
+		Compile: r.compileSlots.Stats(),
+		Execute: r.execSlots.Stats(),
+		Session: r.sessionSlots.Stats(),
+	}
+}
+
 // Close placeholder
 func (r *Runner) Close() error {
 	log.Println("Closing sandbox runner (no-op in local version)")
 	return nil
-}
\ No newline at end of file
+}
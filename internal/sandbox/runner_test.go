@@ -0,0 +1,104 @@
+// internal/sandbox/runner_test.go
+package sandbox
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// shellLanguage runs the submission as a /bin/sh script. It lets tests exercise the runner with
+// shell builtins only, since seccomp forbids the script from executing other programs.
+var shellLanguage = LanguageConfig{
+	Compile: CompileConfig{SrcName: "main.sh"},
+	Run:     RunConfig{Command: "/bin/sh " + PlaceholderSrcPath},
+}
+
+// testRunner returns a runner for cfg using a fresh host temp dir and no compile cache.
+func testRunner(t *testing.T, cfg Config) *Runner {
+	t.Helper()
+	cfg.HostTempDir = t.TempDir()
+	cfg.CompileCacheMaxBytes = 0
+	r, err := NewRunner(cfg)
+	if err != nil {
+		t.Fatalf("NewRunner: %v", err)
+	}
+	return r
+}
+
+func TestRunUsesLanguageProfile(t *testing.T) {
+	requireSandbox(t)
+	// 用shell脚本代替解释器，只检查语言对应的安全配置（进程数上限）
+	cfg := DefaultConfig()
+	cfg.Languages = map[string]LanguageConfig{"python": shellLanguage, "java": shellLanguage, "cpp": shellLanguage}
+	r := testRunner(t, cfg)
+
+	for language, want := range map[string]string{"python": "128", "java": "256", "cpp": "64"} {
+		res := r.Run(context.Background(), language, "ulimit -p", nil, nil)
+		if res.Status != StatusAccepted {
+			t.Errorf("%s: status %s (%s)", language, res.Status, res.Error)
+			continue
+		}
+		if got := strings.TrimSpace(res.Stdout); got != want {
+			t.Errorf("%s: process limit %s, want %s from the language's security profile", language, got, want)
+		}
+	}
+}
+
+func TestRunCasesCannotModifyBuild(t *testing.T) {
+	requireSandbox(t)
+	cfg := DefaultConfig()
+	cfg.Languages = map[string]LanguageConfig{"sh": shellLanguage}
+	r := testRunner(t, cfg)
+
+	// 每个用例都尝试留下文件并篡改自己的源码，后续用例不应看到这些改动
+	source := `[ -e state ] && echo leaked
+echo x > state || echo work dir not writable
+echo 'echo tampered' >> main.sh 2>/dev/null
+echo clean
+`
+	want := "clean"
+	cases := []TestCase{{ExpectedOutput: &want}, {ExpectedOutput: &want}, {ExpectedOutput: &want}}
+	res := r.RunTestCases(context.Background(), "sh", source, cases)
+	for i, caseRes := range res.TestCaseResults {
+		if caseRes.Status != StatusAccepted {
+			t.Errorf("case %d: status %s, stdout %q", i+1, caseRes.Status, caseRes.Stdout)
+		}
+	}
+}
+
+func TestSessionDoesNotHoldExecuteSlot(t *testing.T) {
+	requireSandbox(t)
+	cfg := DefaultConfig()
+	cfg.Languages = map[string]LanguageConfig{"sh": shellLanguage}
+	cfg.MaxParallelExecutions = 1
+	cfg.MaxParallelSessions = 1
+	r := testRunner(t, cfg)
+
+	// 会话程序阻塞在标准输入上，判题仍能获得唯一的执行槽位
+	stdin, stdinWriter := io.Pipe()
+	sessionCfg := cfg
+	sessionCfg.StdinReader = stdin
+	session := make(chan Result, 1)
+	go func() {
+		session <- r.RunWithConfig(context.Background(), "sh", "read x; echo $x", nil, nil, sessionCfg)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for r.Stats().Session.InUse == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if res := r.Run(ctx, "sh", "echo ok", nil, nil); res.Status != StatusAccepted {
+		t.Errorf("run while a session waits for input: status %s (%s)", res.Status, res.Error)
+	}
+
+	stdinWriter.Write([]byte("hello\n"))
+	stdinWriter.Close()
+	if res := <-session; res.Status != StatusAccepted || strings.TrimSpace(res.Stdout) != "hello" {
+		t.Errorf("session: status %s, stdout %q", res.Status, res.Stdout)
+	}
+}
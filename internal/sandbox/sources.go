@@ -0,0 +1,189 @@
+// internal/sandbox/sources.go
+package sandbox
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ErrInvalidSubmission is returned when the files of a multi-file submission cannot be used.
+var ErrInvalidSubmission = errors.New("invalid submission files")
+
+// 多文件提交的数量和解压后总大小上限，防止压缩炸弹
+const (
+	MaxSourceFiles     = 512
+	MaxSourceTotalSize = 16 * 1024 * 1024 // 16MB
+)
+
+// cleanSourcePath validates a path from a multi-file submission and returns it in canonical
+// slash-separated form. Only relative paths that stay inside the run dir are accepted.
+func cleanSourcePath(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, "\\\x00") || path.IsAbs(name) {
+		return "", fmt.Errorf("%w: invalid path %q", ErrInvalidSubmission, name)
+	}
+	for _, elem := range strings.Split(name, "/") {
+		if elem == ".." {
+			return "", fmt.Errorf("%w: path %q escapes the work dir", ErrInvalidSubmission, name)
+		}
+	}
+	cleaned := path.Clean(name)
+	if cleaned == "." {
+		return "", fmt.Errorf("%w: invalid path %q", ErrInvalidSubmission, name)
+	}
+	return cleaned, nil
+}
+
+// submissionFiles merges the archive and file map of cfg into one map of cleaned paths. Entries
+// from the file map replace archive entries with the same path. srcName is the language's main
+// source file; it may only come from the files when sourceCode is empty.
+func submissionFiles(cfg Config, srcName, sourceCode string) (map[string]string, error) {
+	files := make(map[string]string)
+	var total int64
+	add := func(name, content string) error {
+		cleaned, err := cleanSourcePath(name)
+		if err != nil {
+			return err
+		}
+		if old, ok := files[cleaned]; ok {
+			total -= int64(len(old))
+		}
+		files[cleaned] = content
+		total += int64(len(content))
+		if len(files) > MaxSourceFiles {
+			return fmt.Errorf("%w: more than %d files", ErrInvalidSubmission, MaxSourceFiles)
+		}
+		if total > MaxSourceTotalSize {
+			return fmt.Errorf("%w: files exceed %d bytes", ErrInvalidSubmission, MaxSourceTotalSize)
+		}
+		return nil
+	}
+
+	if len(cfg.SourceArchive) > 0 {
+		if err := readSourceArchive(cfg.SourceArchive, add); err != nil {
+			return nil, err
+		}
+	}
+	for name, content := range cfg.SourceFiles {
+		if err := add(name, content); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, ok := files[srcName]; ok && sourceCode != "" {
+		return nil, fmt.Errorf("%w: %s is given both as sourceCode and as a file", ErrInvalidSubmission, srcName)
+	}
+	if _, ok := files[srcName]; !ok && sourceCode == "" && len(files) > 0 {
+		return nil, fmt.Errorf("%w: main source file %s is missing", ErrInvalidSubmission, srcName)
+	}
+	return files, nil
+}
+
+// readSourceArchive calls add for every regular file in a zip, tar or gzip-compressed tar archive.
+// Directories are skipped; links, devices and other special entries are rejected.
+func readSourceArchive(data []byte, add func(name, content string) error) error {
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")) || bytes.HasPrefix(data, []byte("PK\x05\x06")):
+		return readZip(data, add)
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSubmission, err)
+		}
+		defer gz.Close()
+		return readTar(gz, add)
+	default:
+		return readTar(bytes.NewReader(data), add)
+	}
+}
+
+func readZip(data []byte, add func(name, content string) error) error {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSubmission, err)
+	}
+	for _, f := range zr.File {
+		mode := f.Mode()
+		if mode.IsDir() {
+			continue
+		}
+		if !mode.IsRegular() {
+			return fmt.Errorf("%w: %s is not a regular file", ErrInvalidSubmission, f.Name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidSubmission, f.Name, err)
+		}
+		content, err := readSourceEntry(rc, f.Name)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		if err := add(f.Name, content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readTar(r io.Reader, add func(name, content string) error) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSubmission, err)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir, tar.TypeXGlobalHeader:
+			continue
+		case tar.TypeReg:
+		default:
+			return fmt.Errorf("%w: %s is not a regular file", ErrInvalidSubmission, hdr.Name)
+		}
+		content, err := readSourceEntry(tr, hdr.Name)
+		if err != nil {
+			return err
+		}
+		if err := add(hdr.Name, content); err != nil {
+			return err
+		}
+	}
+}
+
+// readSourceEntry reads one archive entry, refusing entries larger than MaxSourceTotalSize
+// regardless of the size the archive claims.
+func readSourceEntry(r io.Reader, name string) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxSourceTotalSize+1))
+	if err != nil {
+		return "", fmt.Errorf("%w: %s: %v", ErrInvalidSubmission, name, err)
+	}
+	if len(data) > MaxSourceTotalSize {
+		return "", fmt.Errorf("%w: files exceed %d bytes", ErrInvalidSubmission, MaxSourceTotalSize)
+	}
+	return string(data), nil
+}
+
+// writeSubmissionFiles writes the files of a multi-file submission below dir.
+func writeSubmissionFiles(dir string, files map[string]string) error {
+	for name, content := range files {
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", name, err)
+		}
+		if err := os.WriteFile(target, []byte(content), 0644); err != nil {
+			return fmt.Errorf("failed to write source file %s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+// internal/sandbox/sources_test.go
+package sandbox
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCleanSourcePath(t *testing.T) {
+	tests := []struct {
+		name string
+		want string // 为空表示应拒绝
+	}{
+		{name: "main.cpp", want: "main.cpp"},
+		{name: "lib/util.h", want: "lib/util.h"},
+		{name: "./lib//util.h", want: "lib/util.h"},
+		{name: "lib/./a/../util.h"},
+		{name: "../main.cpp"},
+		{name: "lib/../../main.cpp"},
+		{name: ".."},
+		{name: "/etc/passwd"},
+		{name: "lib\\util.h"},
+		{name: "main\x00.cpp"},
+		{name: "."},
+		{name: ""},
+	}
+	for _, tt := range tests {
+		got, err := cleanSourcePath(tt.name)
+		if tt.want == "" {
+			if !errors.Is(err, ErrInvalidSubmission) {
+				t.Errorf("cleanSourcePath(%q) = %q, %v; want ErrInvalidSubmission", tt.name, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("cleanSourcePath(%q) = %q, %v; want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+// archiveEntry is one file of a test archive. link makes it a symlink to that target.
+type archiveEntry struct {
+	name, content, link string
+}
+
+func tarArchive(t *testing.T, entries ...archiveEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.content)), Typeflag: tar.TypeReg}
+		if e.link != "" {
+			hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeSymlink, e.link, 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.content)); err != nil && e.link == "" {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func tarGzArchive(t *testing.T, entries ...archiveEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	gw.Write(tarArchive(t, entries...))
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zipArchive(t *testing.T, entries ...archiveEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		content := e.content
+		if e.link != "" {
+			hdr.SetMode(os.ModeSymlink | 0777)
+			content = e.link
+		}
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSubmissionFilesArchive(t *testing.T) {
+	mainSource := archiveEntry{name: "main.cpp", content: "int main() {}"}
+	tooMany := make([]archiveEntry, MaxSourceFiles+1)
+	for i := range tooMany {
+		tooMany[i] = archiveEntry{name: fmt.Sprintf("f%d.h", i)}
+	}
+	half := strings.Repeat("x", MaxSourceTotalSize/2+1)
+
+	tests := []struct {
+		name    string
+		archive []byte
+		files   map[string]string
+		want    []string // 提交中的文件，为nil表示应拒绝
+	}{
+		{name: "tar", archive: tarArchive(t, mainSource, archiveEntry{name: "lib/util.h"}), want: []string{"lib/util.h", "main.cpp"}},
+		{name: "tar.gz", archive: tarGzArchive(t, mainSource), want: []string{"main.cpp"}},
+		{name: "zip", archive: zipArchive(t, mainSource, archiveEntry{name: "./lib/util.h"}), want: []string{"lib/util.h", "main.cpp"}},
+		{name: "tar parent dir", archive: tarArchive(t, mainSource, archiveEntry{name: "../evil.h"})},
+		{name: "tar absolute path", archive: tarArchive(t, mainSource, archiveEntry{name: "/etc/evil.h"})},
+		{name: "tar symlink", archive: tarArchive(t, mainSource, archiveEntry{name: "passwd", link: "/etc/passwd"})},
+		{name: "zip parent dir", archive: zipArchive(t, mainSource, archiveEntry{name: "lib/../../evil.h"})},
+		{name: "zip absolute path", archive: zipArchive(t, mainSource, archiveEntry{name: "/etc/evil.h"})},
+		{name: "zip symlink", archive: zipArchive(t, mainSource, archiveEntry{name: "passwd", link: "/etc/passwd"})},
+		{name: "file map parent dir", files: map[string]string{"main.cpp": "", "../evil.h": ""}},
+		{name: "file map replaces archive entry", archive: tarArchive(t, mainSource), files: map[string]string{"main.cpp": "x"}, want: []string{"main.cpp"}},
+		{name: "too many files", archive: tarArchive(t, append(tooMany, mainSource)...)},
+		{name: "entry too large", archive: tarArchive(t, mainSource, archiveEntry{name: "big.h", content: strings.Repeat("x", MaxSourceTotalSize+1)})},
+		{name: "total too large", archive: tarArchive(t, mainSource, archiveEntry{name: "a.h", content: half}), files: map[string]string{"b.h": half}},
+		{name: "main source missing", archive: tarArchive(t, archiveEntry{name: "util.h"})},
+		{name: "not an archive", archive: []byte("int main() {}")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{SourceArchive: tt.archive, SourceFiles: tt.files}
+			files, err := submissionFiles(cfg, "main.cpp", "")
+			if tt.want == nil {
+				if !errors.Is(err, ErrInvalidSubmission) {
+					t.Errorf("got %d files, %v; want ErrInvalidSubmission", len(files), err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(files) != len(tt.want) {
+				t.Errorf("got files %v, want %v", files, tt.want)
+			}
+			for _, name := range tt.want {
+				if _, ok := files[name]; !ok {
+					t.Errorf("missing %s in %v", name, files)
+				}
+			}
+		})
+	}
+}
+
+func TestSubmissionFilesMainSourceTwice(t *testing.T) {
+	cfg := Config{SourceFiles: map[string]string{"main.cpp": "int main() {}"}}
+	if _, err := submissionFiles(cfg, "main.cpp", "int main() {}"); !errors.Is(err, ErrInvalidSubmission) {
+		t.Errorf("main source given as sourceCode and file: %v, want ErrInvalidSubmission", err)
+	}
+}
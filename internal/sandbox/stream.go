@@ -0,0 +1,46 @@
+// internal/sandbox/stream.go
+package sandbox
+
+// Output streams reported through OutputSink
+const (
+	StreamCompile = "compile" // Output of the compile command
+	StreamStdout  = "stdout"  // Standard output of the user's program
+	StreamStderr  = "stderr"  // Standard error of the user's program
+)
+
+// OutputChunk is a piece of output produced while a request is running.
+type OutputChunk struct {
+	Stream   string `json:"stream"`             // One of the Stream* constants
+	TestCase int    `json:"testCase,omitempty"` // 1-based test case number, 0 for single runs and compile output
+	Data     string `json:"data"`
+}
+
+// OutputSink receives output as it is produced. It may be called concurrently from the
+// goroutines copying a program's stdout and stderr, and should return quickly since the
+// program blocks on its pipe meanwhile. Streamed output is subject to the same size limits
+// as the captured output.
+type OutputSink func(OutputChunk)
+
+// sinkWriter forwards writes to an OutputSink as chunks of one stream.
+type sinkWriter struct {
+	sink   OutputSink
+	stream string
+}
+
+func (w sinkWriter) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		w.sink(OutputChunk{Stream: w.stream, Data: string(p)})
+	}
+	return len(p), nil
+}
+
+// withTestCase returns a sink that tags every chunk with the 1-based test case number n.
+func (sink OutputSink) withTestCase(n int) OutputSink {
+	if sink == nil {
+		return nil
+	}
+	return func(chunk OutputChunk) {
+		chunk.TestCase = n
+		sink(chunk)
+	}
+}
@@ -0,0 +1,149 @@
+// internal/sandbox/subtask.go
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Subtask scoring modes selectable through Subtask.Scoring
+const (
+	ScoringAll = "all" // Full score only if every case passes (default)
+	ScoringMin = "min" // Score times the lowest per-case score ratio in the group (partial checker points count)
+	ScoringSum = "sum" // Score times the earned share of the group's case weights (partial checker points count)
+)
+
+// ErrInvalidSubtasks is returned when the subtasks of a request do not fit its test cases.
+var ErrInvalidSubtasks = errors.New("invalid subtasks")
+
+// Subtask groups test cases into an IOI-style scored unit.
+type Subtask struct {
+	Name          string `json:"name"`          // Display name (default: "subtask N")
+	Score         int    `json:"score"`         // Points awarded for the subtask
+	Cases         []int  `json:"cases"`         // 0-based indexes into the request's test cases
+	Scoring       string `json:"scoring"`       // One of the Scoring* constants (default: all)
+	DependsOn     []int  `json:"dependsOn"`     // 0-based indexes of earlier subtasks that must be fully passed
+	StopOnFailure bool   `json:"stopOnFailure"` // Skip the rest of the group after its first failed case
+}
+
+// SubtaskResult is the score breakdown of one subtask.
+type SubtaskResult struct {
+	Name       string
+	Status     Status // Accepted if fully passed, Skipped if a dependency failed, else the first failed case's status
+	Score      int
+	TotalScore int
+	Cases      []int
+}
+
+// validateSubtasks checks case indexes and that dependencies only point to earlier subtasks.
+func validateSubtasks(subtasks []Subtask, numCases int) error {
+	for i, st := range subtasks {
+		if len(st.Cases) == 0 {
+			return fmt.Errorf("%w: subtask %d has no test cases", ErrInvalidSubtasks, i)
+		}
+		for _, c := range st.Cases {
+			if c < 0 || c >= numCases {
+				return fmt.Errorf("%w: subtask %d refers to test case %d of %d", ErrInvalidSubtasks, i, c, numCases)
+			}
+		}
+		for _, d := range st.DependsOn {
+			if d < 0 || d >= i {
+				return fmt.Errorf("%w: subtask %d may only depend on earlier subtasks, not %d", ErrInvalidSubtasks, i, d)
+			}
+		}
+		switch st.Scoring {
+		case "", ScoringAll, ScoringMin, ScoringSum:
+		default:
+			return fmt.Errorf("%w: subtask %d has unknown scoring %q", ErrInvalidSubtasks, i, st.Scoring)
+		}
+	}
+	return nil
+}
+
+// skippedResult is the result of a test case worth totalScore that was not run.
+func skippedResult(reason string, totalScore int) Result {
+	return Result{Status: StatusSkipped, ExitCode: -1, Error: reason, TotalScore: totalScore}
+}
+
+// judgeSubtasks runs the cases of each subtask in order through run, which is called at most once
+// per case. Cases are not run when a dependency of their subtask failed or an earlier case of a
+// stop-on-failure group failed, unless another subtask still needs them. Cases outside every
+// subtask are run last. It returns the per-case results, with skipped cases filled in.
+func judgeSubtasks(subtasks []Subtask, testCases []TestCase, run func(i int) Result) ([]Result, []SubtaskResult) {
+	numCases := len(testCases)
+	caseResults := make([]Result, numCases)
+	ran := make([]bool, numCases)
+	runCase := func(i int) Result {
+		if !ran[i] {
+			caseResults[i] = run(i)
+			ran[i] = true
+		}
+		return caseResults[i]
+	}
+
+	results := make([]SubtaskResult, len(subtasks))
+	covered := make([]bool, numCases)
+	for i, st := range subtasks {
+		res := SubtaskResult{Name: st.Name, Status: StatusAccepted, TotalScore: st.Score, Cases: st.Cases}
+		if res.Name == "" {
+			res.Name = fmt.Sprintf("subtask %d", i+1)
+		}
+		for _, c := range st.Cases {
+			covered[c] = true
+		}
+		for _, d := range st.DependsOn {
+			if results[d].Status != StatusAccepted {
+				res.Status = StatusSkipped
+				break
+			}
+		}
+		if res.Status == StatusSkipped {
+			results[i] = res
+			continue
+		}
+
+		var weights int
+		for _, c := range st.Cases {
+			weights += testCases[c].weight()
+		}
+		// 部分正确的用例按检查器给出的比例计分
+		earned, minRatio := 0.0, 1.0
+		for _, c := range st.Cases {
+			caseRes := runCase(c)
+			ratio := caseRes.scoreRatio()
+			earned += float64(testCases[c].weight()) * ratio
+			minRatio = math.Min(minRatio, ratio)
+			if !caseRes.IsOK() && res.Status == StatusAccepted {
+				res.Status = caseRes.Status
+			}
+			if !caseRes.IsOK() && st.StopOnFailure {
+				break
+			}
+		}
+
+		switch st.Scoring {
+		case ScoringSum:
+			res.Score = scaledScore(st.Score, earned/float64(weights))
+		case ScoringMin:
+			res.Score = scaledScore(st.Score, minRatio)
+		default:
+			if res.Status == StatusAccepted {
+				res.Score = st.Score
+			}
+		}
+		results[i] = res
+	}
+
+	for c := 0; c < numCases; c++ {
+		if !covered[c] {
+			runCase(c)
+		}
+	}
+	for c := range caseResults {
+		if !ran[c] {
+			caseResults[c] = skippedResult("not run: its subtask failed or depends on a failed subtask", testCases[c].weight())
+		}
+	}
+	return caseResults, results
+}
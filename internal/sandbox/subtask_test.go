@@ -0,0 +1,158 @@
+// internal/sandbox/subtask_test.go
+package sandbox
+
+import (
+	"fmt"
+	"testing"
+)
+
+// caseOutcome is the result a test case produces when judgeSubtasks runs it.
+type caseOutcome struct {
+	status  Status
+	partial float64 // 检查器给出的部分分比例（仅 Wrong Answer）
+	weight  int
+}
+
+var (
+	passCase = caseOutcome{status: StatusAccepted}
+	failCase = caseOutcome{status: StatusWrongAnswer}
+)
+
+func TestJudgeSubtasks(t *testing.T) {
+	tests := []struct {
+		name         string
+		cases        []caseOutcome
+		subtasks     []Subtask
+		wantScores   []int
+		wantStatuses []Status
+		wantRun      []int // 按运行顺序排列的用例
+	}{
+		{
+			name:         "all",
+			cases:        []caseOutcome{passCase, passCase, {status: StatusWrongAnswer, partial: 0.9}},
+			subtasks:     []Subtask{{Score: 40, Cases: []int{0, 1}}, {Score: 60, Cases: []int{1, 2}}},
+			wantScores:   []int{40, 0},
+			wantStatuses: []Status{StatusAccepted, StatusWrongAnswer},
+			wantRun:      []int{0, 1, 2},
+		},
+		{
+			name:         "min",
+			cases:        []caseOutcome{passCase, {status: StatusWrongAnswer, partial: 0.5}, {status: StatusWrongAnswer, partial: 0.8}},
+			subtasks:     []Subtask{{Score: 30, Cases: []int{0, 1, 2}, Scoring: ScoringMin}},
+			wantScores:   []int{15},
+			wantStatuses: []Status{StatusWrongAnswer},
+			wantRun:      []int{0, 1, 2},
+		},
+		{
+			name:         "sum by case weight",
+			cases:        []caseOutcome{{status: StatusAccepted, weight: 1}, failCase, {status: StatusWrongAnswer, partial: 0.5, weight: 2}},
+			subtasks:     []Subtask{{Score: 100, Cases: []int{0, 1, 2}, Scoring: ScoringSum}},
+			wantScores:   []int{50},
+			wantStatuses: []Status{StatusWrongAnswer},
+			wantRun:      []int{0, 1, 2},
+		},
+		{
+			name:         "failed dependency skips",
+			cases:        []caseOutcome{failCase, passCase, passCase},
+			subtasks:     []Subtask{{Score: 20, Cases: []int{0}}, {Score: 30, Cases: []int{1}, DependsOn: []int{0}}, {Score: 50, Cases: []int{2}}},
+			wantScores:   []int{0, 0, 50},
+			wantStatuses: []Status{StatusWrongAnswer, StatusSkipped, StatusAccepted},
+			wantRun:      []int{0, 2},
+		},
+		{
+			name:         "skipped dependency skips",
+			cases:        []caseOutcome{failCase, passCase, passCase},
+			subtasks:     []Subtask{{Score: 20, Cases: []int{0}}, {Score: 30, Cases: []int{1}, DependsOn: []int{0}}, {Score: 50, Cases: []int{2}, DependsOn: []int{1}}},
+			wantScores:   []int{0, 0, 0},
+			wantStatuses: []Status{StatusWrongAnswer, StatusSkipped, StatusSkipped},
+			wantRun:      []int{0},
+		},
+		{
+			name:         "passed dependency",
+			cases:        []caseOutcome{passCase, passCase},
+			subtasks:     []Subtask{{Score: 20, Cases: []int{0}}, {Score: 80, Cases: []int{0, 1}, DependsOn: []int{0}}},
+			wantScores:   []int{20, 80},
+			wantStatuses: []Status{StatusAccepted, StatusAccepted},
+			wantRun:      []int{0, 1},
+		},
+		{
+			name:         "stop on failure",
+			cases:        []caseOutcome{passCase, failCase, passCase, passCase},
+			subtasks:     []Subtask{{Score: 50, Cases: []int{0, 1, 2}, StopOnFailure: true}, {Score: 50, Cases: []int{3}}},
+			wantScores:   []int{0, 50},
+			wantStatuses: []Status{StatusWrongAnswer, StatusAccepted},
+			wantRun:      []int{0, 1, 3},
+		},
+		{
+			name:         "case needed by another subtask",
+			cases:        []caseOutcome{failCase, passCase},
+			subtasks:     []Subtask{{Score: 50, Cases: []int{0}}, {Score: 20, Cases: []int{1}, DependsOn: []int{0}}, {Score: 30, Cases: []int{1}}},
+			wantScores:   []int{0, 0, 30},
+			wantStatuses: []Status{StatusWrongAnswer, StatusSkipped, StatusAccepted},
+			wantRun:      []int{0, 1},
+		},
+		{
+			name:         "cases outside subtasks run last",
+			cases:        []caseOutcome{passCase, passCase, passCase},
+			subtasks:     []Subtask{{Score: 100, Cases: []int{1}}},
+			wantScores:   []int{100},
+			wantStatuses: []Status{StatusAccepted},
+			wantRun:      []int{1, 0, 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testCases := make([]TestCase, len(tt.cases))
+			for i, c := range tt.cases {
+				testCases[i].Score = c.weight
+			}
+			var runs []int
+			caseResults, results := judgeSubtasks(tt.subtasks, testCases, func(i int) Result {
+				runs = append(runs, i)
+				return Result{Status: tt.cases[i].status, PartialScore: tt.cases[i].partial}
+			})
+
+			if fmt.Sprint(runs) != fmt.Sprint(tt.wantRun) {
+				t.Errorf("ran cases %v, want %v", runs, tt.wantRun)
+			}
+			for i, res := range results {
+				if res.Score != tt.wantScores[i] || res.Status != tt.wantStatuses[i] {
+					t.Errorf("subtask %d: %s with %d points, want %s with %d", i, res.Status, res.Score, tt.wantStatuses[i], tt.wantScores[i])
+				}
+				if res.TotalScore != tt.subtasks[i].Score {
+					t.Errorf("subtask %d: total score %d, want %d", i, res.TotalScore, tt.subtasks[i].Score)
+				}
+			}
+			ran := make(map[int]bool)
+			for _, c := range runs {
+				ran[c] = true
+			}
+			for i, res := range caseResults {
+				if !ran[i] && res.Status != StatusSkipped {
+					t.Errorf("case %d was not run but has status %s", i, res.Status)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateSubtasks(t *testing.T) {
+	tests := []struct {
+		name     string
+		subtasks []Subtask
+		ok       bool
+	}{
+		{name: "valid", subtasks: []Subtask{{Cases: []int{0}}, {Cases: []int{1}, DependsOn: []int{0}, Scoring: ScoringSum}}, ok: true},
+		{name: "no cases", subtasks: []Subtask{{}}},
+		{name: "case out of range", subtasks: []Subtask{{Cases: []int{2}}}},
+		{name: "negative case", subtasks: []Subtask{{Cases: []int{-1}}}},
+		{name: "depends on itself", subtasks: []Subtask{{Cases: []int{0}, DependsOn: []int{0}}}},
+		{name: "depends on later subtask", subtasks: []Subtask{{Cases: []int{0}, DependsOn: []int{1}}, {Cases: []int{1}}}},
+		{name: "unknown scoring", subtasks: []Subtask{{Cases: []int{0}, Scoring: "max"}}},
+	}
+	for _, tt := range tests {
+		if err := validateSubtasks(tt.subtasks, 2); (err == nil) != tt.ok {
+			t.Errorf("%s: validateSubtasks = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
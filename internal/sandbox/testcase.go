@@ -0,0 +1,165 @@
+// internal/sandbox/testcase.go
+package sandbox
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// MaxExecuteTimeLimit is the largest per-run time limit a request may ask for.
+	MaxExecuteTimeLimit = 30 * time.Second
+	// MaxExecuteMemoryLimit is the largest per-run memory limit (bytes) a request may ask for.
+	MaxExecuteMemoryLimit int64 = 4 * 1024 * 1024 * 1024
+	// MaxFileSizeLimit is the largest file size limit (bytes) a request may ask for.
+	MaxFileSizeLimit int64 = 1024 * 1024 * 1024
+	// MaxOpenFilesLimit is the largest open file descriptor limit a request may ask for.
+	MaxOpenFilesLimit = 4096
+	// MaxProcessLimit is the largest process/thread limit a request may ask for.
+	MaxProcessLimit = 1024
+)
+
+// TestCase is a single input/expected-output pair judged against a compiled submission.
+type TestCase struct {
+	Stdin          *string `json:"stdin"`          // Optional standard input
+	ExpectedOutput *string `json:"expectedOutput"` // Optional expected output for comparison
+	Timeout        *int    `json:"timeout"`        // Optional per-case CPU time limit in seconds (overrides the request)
+	WallTimeout    *int    `json:"wallTimeout"`    // Optional per-case wall-clock limit in seconds (overrides the request)
+	MemoryLimit    *int    `json:"memoryLimit"`    // Optional per-case memory limit in MB (overrides the request)
+	Score          int     `json:"score"`          // Score weight of this case (0 = weight 1)
+	Sample         bool    `json:"sample"`         // Sample case, run first under the samples-first policy
+
+	// Optional file I/O for this case (override the request's files when set)
+	InputFiles          map[string]string `json:"inputFiles,omitempty"`          // Files written to the work dir before the run
+	ExpectedOutputFiles map[string]string `json:"expectedOutputFiles,omitempty"` // Expected contents of output files
+}
+
+// weight returns the score awarded when the case is accepted.
+func (tc TestCase) weight() int {
+	if tc.Score <= 0 {
+		return 1
+	}
+	return tc.Score
+}
+
+// resolveTimeout converts a requested timeout in seconds to a duration.
+// ok is false when the request is absent or outside the allowed range.
+func resolveTimeout(seconds *int) (timeout time.Duration, ok bool) {
+	if seconds == nil || *seconds <= 0 {
+		return 0, false
+	}
+	timeout = time.Duration(*seconds) * time.Second
+	if timeout > MaxExecuteTimeLimit {
+		return 0, false
+	}
+	return timeout, true
+}
+
+// resolveMemoryLimit converts a requested memory limit in MB to bytes, capped at MaxExecuteMemoryLimit.
+// ok is false when no limit was requested.
+func resolveMemoryLimit(megabytes *int) (limit int64, ok bool) {
+	if megabytes == nil || *megabytes <= 0 {
+		return 0, false
+	}
+	limit = int64(*megabytes) * 1024 * 1024
+	if limit > MaxExecuteMemoryLimit {
+		limit = MaxExecuteMemoryLimit
+	}
+	return limit, true
+}
+
+// resolveStackLimit converts a requested stack limit in MB to bytes: -1 means unlimited,
+// 0 means the request did not set one. Stack pages are still charged to the memory limit.
+func resolveStackLimit(megabytes *int) int64 {
+	if megabytes == nil || *megabytes == 0 {
+		return 0
+	}
+	if *megabytes < 0 {
+		return -1
+	}
+	return int64(*megabytes) * 1024 * 1024
+}
+
+// resolveFileSizeLimit converts a requested file size limit in MB to bytes, capped at MaxFileSizeLimit.
+// It returns 0 when the request did not set one.
+func resolveFileSizeLimit(megabytes *int) int64 {
+	if megabytes == nil || *megabytes <= 0 {
+		return 0
+	}
+	limit := int64(*megabytes) * 1024 * 1024
+	if limit > MaxFileSizeLimit {
+		limit = MaxFileSizeLimit
+	}
+	return limit
+}
+
+// resolveCount returns a requested count limit capped at max, or 0 when the request did not set one.
+func resolveCount(count *int, max int) int {
+	if count == nil || *count <= 0 {
+		return 0
+	}
+	if *count > max {
+		return max
+	}
+	return *count
+}
+
+// caseConfig derives the execution config for one test case, applying the case's own limits to cfg.
+func caseConfig(cfg Config, tc TestCase) Config {
+	if timeout, ok := resolveTimeout(tc.Timeout); ok {
+		cfg.DefaultExecuteTimeLimit = timeout
+		cfg.ExecTimeout = timeout
+		cfg.UserSpecifiedTimeout = true
+	}
+	if wall, ok := resolveTimeout(tc.WallTimeout); ok {
+		cfg.WallTimeLimit = wall
+	}
+	if limit, ok := resolveMemoryLimit(tc.MemoryLimit); ok {
+		cfg.DefaultExecuteMemoryLimit = limit
+	}
+	if tc.InputFiles != nil {
+		cfg.InputFiles = tc.InputFiles
+	}
+	if tc.ExpectedOutputFiles != nil {
+		cfg.ExpectedOutputFiles = tc.ExpectedOutputFiles
+	}
+	return cfg
+}
+
+// aggregateResults folds per-case results into a single verdict: the status of the first
+// non-accepted case (Accepted if all pass), the maximum times and memory, and the summed score.
+// Skipped cases only contribute their weight to the total score.
+func aggregateResults(caseResults []Result) Result {
+	agg := Result{
+		Status:          StatusAccepted,
+		TimeUsedMillis:  -1,
+		CPUTimeMillis:   -1,
+		MemoryUsedKB:    -1,
+		TestCaseResults: caseResults,
+	}
+	for i, res := range caseResults {
+		if res.Status == StatusSkipped {
+			agg.TotalScore += res.TotalScore
+			continue
+		}
+		if res.TimeUsedMillis > agg.TimeUsedMillis {
+			agg.TimeUsedMillis = res.TimeUsedMillis
+		}
+		if res.CPUTimeMillis > agg.CPUTimeMillis {
+			agg.CPUTimeMillis = res.CPUTimeMillis
+		}
+		if res.MemoryUsedKB > agg.MemoryUsedKB {
+			agg.MemoryUsedKB = res.MemoryUsedKB
+		}
+		agg.Score += res.Score
+		agg.TotalScore += res.TotalScore
+
+		if agg.Status == StatusAccepted && !res.IsOK() {
+			agg.Status = res.Status
+			agg.ExitCode = res.ExitCode
+			agg.Signal = res.Signal
+			agg.Error = fmt.Sprintf("test case %d: %s", i+1, res.Error)
+		}
+	}
+	return agg
+}
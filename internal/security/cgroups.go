@@ -1,16 +1,20 @@
 package security
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/CodeRushOJ/croj-sandbox/internal/util"
 )
 
-// SetupCgroups 设置cgroup资源限制
-func SetupCgroups(cgroupID string, pid int, profile *SecurityProfile) (*CgroupManager, error) {
+// SetupCgroups 创建cgroup并设置资源限制，进程稍后通过 AddProcess 加入
+func SetupCgroups(cgroupID string, profile *SecurityProfile) (*CgroupManager, error) {
 	// 判断使用v1还是v2版本的cgroup
 	cgroupVersion := detectCgroupVersion()
 	util.DebugLog("检测到cgroup版本: %d", cgroupVersion)
@@ -19,21 +23,244 @@ func SetupCgroups(cgroupID string, pid int, profile *SecurityProfile) (*CgroupMa
 	var err error
 
 	if cgroupVersion == 2 {
-		manager, err = setupCgroupsV2(cgroupID, pid, profile)
+		manager, err = setupCgroupsV2(cgroupID, profile)
 	} else {
-		manager, err = setupCgroupsV1(cgroupID, pid, profile)
+		manager, err = setupCgroupsV1(cgroupID, profile)
 	}
 
 	if err != nil {
+		// 清理已创建的部分目录
+		CleanupCgroups(manager)
 		return nil, err
 	}
 
+	manager.Initialized = true
 	return manager, nil
 }
 
+// ProcsFiles 返回加入该cgroup需要写入的 cgroup.procs 文件列表
+// 使用 cgroup.procs 而不是 tasks，以便整个进程（所有线程）一起迁移
+func (m *CgroupManager) ProcsFiles() []string {
+	files := make([]string, 0, len(m.Paths))
+	for _, path := range m.Paths {
+		files = append(files, filepath.Join(path, "cgroup.procs"))
+	}
+	return files
+}
+
+// AddProcess 将进程加入cgroup
+func (m *CgroupManager) AddProcess(pid int) error {
+	return joinCgroups(m.ProcsFiles(), pid)
+}
+
+// memoryPath 返回内存控制器所在的cgroup目录
+func (m *CgroupManager) memoryPath() (string, error) {
+	if len(m.Paths) == 0 {
+		return "", fmt.Errorf("cgroup %s 未初始化", m.GroupID)
+	}
+	// v1中第一个目录是内存控制器，v2只有一个目录
+	return m.Paths[0], nil
+}
+
+// PeakMemoryBytes 返回cgroup内所有进程的内存使用峰值（字节）
+// v2 读取 memory.peak (Linux 5.19+)，v1 读取 memory.max_usage_in_bytes
+func (m *CgroupManager) PeakMemoryBytes() (int64, error) {
+	path, err := m.memoryPath()
+	if err != nil {
+		return -1, err
+	}
+	file := "memory.max_usage_in_bytes"
+	if m.Version == 2 {
+		file = "memory.peak"
+	}
+	data, err := os.ReadFile(filepath.Join(path, file))
+	if err != nil {
+		return -1, fmt.Errorf("读取内存峰值失败: %w", err)
+	}
+	peak, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return -1, fmt.Errorf("解析内存峰值失败: %w", err)
+	}
+	return peak, nil
+}
+
+// CPUUsage 返回cgroup内所有进程消耗的CPU时间（用户态+内核态）
+// v2 读取 cpu.stat 的 usage_usec，v1 读取 cpuacct.usage
+func (m *CgroupManager) CPUUsage() (time.Duration, error) {
+	if m.CPUAcctPath == "" {
+		return 0, fmt.Errorf("cgroup %s 不统计CPU时间", m.GroupID)
+	}
+	if m.Version == 2 {
+		data, err := os.ReadFile(filepath.Join(m.CPUAcctPath, "cpu.stat"))
+		if err != nil {
+			return 0, fmt.Errorf("读取CPU使用量失败: %w", err)
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 2 && fields[0] == "usage_usec" {
+				usec, err := strconv.ParseInt(fields[1], 10, 64)
+				if err != nil {
+					return 0, fmt.Errorf("解析CPU使用量失败: %w", err)
+				}
+				return time.Duration(usec) * time.Microsecond, nil
+			}
+		}
+		return 0, fmt.Errorf("cpu.stat 中没有 usage_usec")
+	}
+	data, err := os.ReadFile(filepath.Join(m.CPUAcctPath, "cpuacct.usage"))
+	if err != nil {
+		return 0, fmt.Errorf("读取CPU使用量失败: %w", err)
+	}
+	nsec, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("解析CPU使用量失败: %w", err)
+	}
+	return time.Duration(nsec), nil
+}
+
+// OOMKilled 返回cgroup内是否有进程因超出内存限制被OOM killer终止
+// v2 读取 memory.events，v1 读取 memory.oom_control (Linux 4.13+)
+func (m *CgroupManager) OOMKilled() (bool, error) {
+	path, err := m.memoryPath()
+	if err != nil {
+		return false, err
+	}
+	file := "memory.oom_control"
+	if m.Version == 2 {
+		file = "memory.events"
+	}
+	data, err := os.ReadFile(filepath.Join(path, file))
+	if err != nil {
+		return false, fmt.Errorf("读取OOM事件失败: %w", err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "oom_kill" {
+			count, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return false, fmt.Errorf("解析OOM事件失败: %w", err)
+			}
+			return count > 0, nil
+		}
+	}
+	return false, fmt.Errorf("%s 中没有 oom_kill 计数", file)
+}
+
+// killPollInterval 和 killTimeout 控制终止cgroup后等待进程全部退出的方式
+const (
+	killPollInterval = 5 * time.Millisecond
+	killTimeout      = 2 * time.Second
+)
+
+// Kill 终止cgroup中的所有进程并等待它们退出
+// v2 优先使用 cgroup.kill (Linux 5.14+)；否则先冻结cgroup，避免进程在逐个终止期间继续fork，再对每个进程发送SIGKILL
+func (m *CgroupManager) Kill() error {
+	if len(m.Paths) == 0 {
+		return nil
+	}
+	if m.Version == 2 {
+		err := os.WriteFile(filepath.Join(m.Paths[0], "cgroup.kill"), []byte("1"), 0644)
+		if err == nil {
+			return m.waitEmpty()
+		}
+		util.DebugLog("cgroup.kill 不可用，逐个终止进程: %v", err)
+	}
+
+	frozen := m.freeze(true)
+	var errs []error
+	pids, err := m.procs()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	for _, pid := range pids {
+		if err := syscall.Kill(pid, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+			errs = append(errs, fmt.Errorf("终止进程 %d 失败: %w", pid, err))
+		}
+	}
+	// v1中被冻结的进程在解冻后才会处理SIGKILL
+	if frozen {
+		m.freeze(false)
+	}
+	if err := m.waitEmpty(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// freeze 冻结或解冻cgroup中的进程，返回是否成功
+func (m *CgroupManager) freeze(frozen bool) bool {
+	var file, value string
+	switch {
+	case m.Version == 2:
+		file, value = filepath.Join(m.Paths[0], "cgroup.freeze"), "0"
+		if frozen {
+			value = "1"
+		}
+	case m.FreezerPath != "":
+		file, value = filepath.Join(m.FreezerPath, "freezer.state"), "THAWED"
+		if frozen {
+			value = "FROZEN"
+		}
+	default:
+		return false
+	}
+	if err := os.WriteFile(file, []byte(value), 0644); err != nil {
+		util.WarnLog("设置cgroup冻结状态失败: %v", err)
+		return false
+	}
+	return true
+}
+
+// procs 返回cgroup中的所有进程ID
+func (m *CgroupManager) procs() ([]int, error) {
+	data, err := os.ReadFile(filepath.Join(m.Paths[0], "cgroup.procs"))
+	if err != nil {
+		return nil, fmt.Errorf("读取cgroup进程列表失败: %w", err)
+	}
+	var pids []int
+	for _, field := range strings.Fields(string(data)) {
+		if pid, err := strconv.Atoi(field); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+// waitEmpty 等待cgroup中的进程全部退出，必要时重复发送SIGKILL
+func (m *CgroupManager) waitEmpty() error {
+	deadline := time.Now().Add(killTimeout)
+	for {
+		pids, err := m.procs()
+		if err != nil {
+			return err
+		}
+		if len(pids) == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("cgroup %s 中仍有 %d 个进程", m.GroupID, len(pids))
+		}
+		for _, pid := range pids {
+			_ = syscall.Kill(pid, syscall.SIGKILL)
+		}
+		time.Sleep(killPollInterval)
+	}
+}
+
+// joinCgroups 将进程写入每个 cgroup.procs 文件
+func joinCgroups(procsFiles []string, pid int) error {
+	pidStr := strconv.Itoa(pid)
+	for _, procsFile := range procsFiles {
+		if err := os.WriteFile(procsFile, []byte(pidStr), 0644); err != nil {
+			return fmt.Errorf("将进程 %d 添加到cgroup %s 失败: %w", pid, procsFile, err)
+		}
+	}
+	return nil
+}
+
 // CleanupCgroups 清理cgroup资源
 func CleanupCgroups(manager *CgroupManager) error {
-	if manager == nil || !manager.Initialized {
+	if manager == nil || len(manager.Paths) == 0 {
 		return nil
 	}
 
@@ -41,13 +268,17 @@ func CleanupCgroups(manager *CgroupManager) error {
 	util.DebugLog("清理cgroup: %s", manager.GroupID)
 
 	// 检查cgroup版本并执行对应的清理
-	if manager.BasePath == "/sys/fs/cgroup/unified" {
+	var err error
+	if manager.Version == 2 {
 		// cgroup v2清理
-		return cleanupCgroupV2(manager)
+		err = cleanupCgroupV2(manager)
 	} else {
 		// cgroup v1清理
-		return cleanupCgroupV1(manager)
+		err = cleanupCgroupV1(manager)
 	}
+	manager.Paths = nil
+	manager.Initialized = false
+	return err
 }
 
 // detectCgroupVersion 检测系统使用的cgroup版本
@@ -67,36 +298,63 @@ func detectCgroupVersion() int {
 }
 
 // setupCgroupsV1 配置cgroup v1资源限制
-func setupCgroupsV1(cgroupID string, pid int, profile *SecurityProfile) (*CgroupManager, error) {
+func setupCgroupsV1(cgroupID string, profile *SecurityProfile) (*CgroupManager, error) {
 	manager := &CgroupManager{
-		GroupID: cgroupID,
+		BasePath: "/sys/fs/cgroup",
+		GroupID:  cgroupID,
+		Version:  1,
 	}
 
 	// 创建内存控制器
 	memCgroupPath := filepath.Join("/sys/fs/cgroup/memory", "croj", cgroupID)
 	if err := os.MkdirAll(memCgroupPath, 0755); err != nil {
-		return nil, fmt.Errorf("创建内存cgroup失败: %w", err)
+		return manager, fmt.Errorf("创建内存cgroup失败: %w", err)
 	}
+	manager.Paths = append(manager.Paths, memCgroupPath)
 
 	// 创建CPU控制器
 	cpuCgroupPath := filepath.Join("/sys/fs/cgroup/cpu", "croj", cgroupID)
 	if err := os.MkdirAll(cpuCgroupPath, 0755); err != nil {
-		return nil, fmt.Errorf("创建CPU cgroup失败: %w", err)
+		return manager, fmt.Errorf("创建CPU cgroup失败: %w", err)
+	}
+	manager.Paths = append(manager.Paths, cpuCgroupPath)
+	manager.CPUAcctPath = cpuCgroupPath
+
+	// cpuacct与cpu未共同挂载时单独创建，用于统计CPU时间
+	if _, err := os.Stat(filepath.Join(cpuCgroupPath, "cpuacct.usage")); err != nil {
+		cpuacctCgroupPath := filepath.Join("/sys/fs/cgroup/cpuacct", "croj", cgroupID)
+		if err := os.MkdirAll(cpuacctCgroupPath, 0755); err != nil {
+			util.WarnLog("创建cpuacct cgroup失败: %v", err)
+			manager.CPUAcctPath = ""
+		} else {
+			manager.Paths = append(manager.Paths, cpuacctCgroupPath)
+			manager.CPUAcctPath = cpuacctCgroupPath
+		}
 	}
-	
+
 	// 创建pids控制器
 	pidsCgroupPath := filepath.Join("/sys/fs/cgroup/pids", "croj", cgroupID)
 	if err := os.MkdirAll(pidsCgroupPath, 0755); err != nil {
-		return nil, fmt.Errorf("创建pids cgroup失败: %w", err)
+		return manager, fmt.Errorf("创建pids cgroup失败: %w", err)
+	}
+	manager.Paths = append(manager.Paths, pidsCgroupPath)
+
+	// 创建freezer控制器，超时后冻结整个进程树再终止，不可用时退化为直接终止
+	freezerCgroupPath := filepath.Join("/sys/fs/cgroup/freezer", "croj", cgroupID)
+	if err := os.MkdirAll(freezerCgroupPath, 0755); err != nil {
+		util.WarnLog("创建freezer cgroup失败: %v", err)
+	} else {
+		manager.Paths = append(manager.Paths, freezerCgroupPath)
+		manager.FreezerPath = freezerCgroupPath
 	}
 
 	// 设置内存限制
 	if profile.MemoryLimitBytes > 0 {
 		memLimitPath := filepath.Join(memCgroupPath, "memory.limit_in_bytes")
 		if err := os.WriteFile(memLimitPath, []byte(fmt.Sprintf("%d", profile.MemoryLimitBytes)), 0644); err != nil {
-			return nil, fmt.Errorf("设置内存限制失败: %w", err)
+			return manager, fmt.Errorf("设置内存限制失败: %w", err)
 		}
-		
+
 		// 禁用内存交换，确保更准确的内存限制
 		swapLimitPath := filepath.Join(memCgroupPath, "memory.swappiness")
 		if err := os.WriteFile(swapLimitPath, []byte("0"), 0644); err != nil {
@@ -110,13 +368,13 @@ func setupCgroupsV1(cgroupID string, pid int, profile *SecurityProfile) (*Cgroup
 		cpuQuota := profile.CPULimit * 1000
 		cpuQuotaPath := filepath.Join(cpuCgroupPath, "cpu.cfs_quota_us")
 		if err := os.WriteFile(cpuQuotaPath, []byte(fmt.Sprintf("%d", cpuQuota)), 0644); err != nil {
-			return nil, fmt.Errorf("设置CPU配额失败: %w", err)
+			return manager, fmt.Errorf("设置CPU配额失败: %w", err)
 		}
-		
+
 		// CPU周期（微秒）：默认100000
 		cpuPeriodPath := filepath.Join(cpuCgroupPath, "cpu.cfs_period_us")
 		if err := os.WriteFile(cpuPeriodPath, []byte("100000"), 0644); err != nil {
-			return nil, fmt.Errorf("设置CPU周期失败: %w", err)
+			return manager, fmt.Errorf("设置CPU周期失败: %w", err)
 		}
 	}
 
@@ -124,62 +382,51 @@ func setupCgroupsV1(cgroupID string, pid int, profile *SecurityProfile) (*Cgroup
 	if profile.PidsLimit > 0 {
 		pidsMaxPath := filepath.Join(pidsCgroupPath, "pids.max")
 		if err := os.WriteFile(pidsMaxPath, []byte(fmt.Sprintf("%d", profile.PidsLimit)), 0644); err != nil {
-			return nil, fmt.Errorf("设置进程数限制失败: %w", err)
+			return manager, fmt.Errorf("设置进程数限制失败: %w", err)
 		}
 	}
 
-	// 将进程加入到cgroup
-	pidStr := strconv.Itoa(pid)
-	
-	// 添加到内存控制器
-	memTasksPath := filepath.Join(memCgroupPath, "tasks")
-	if err := os.WriteFile(memTasksPath, []byte(pidStr), 0644); err != nil {
-		return nil, fmt.Errorf("将进程添加到内存cgroup失败: %w", err)
-	}
-	
-	// 添加到CPU控制器
-	cpuTasksPath := filepath.Join(cpuCgroupPath, "tasks")
-	if err := os.WriteFile(cpuTasksPath, []byte(pidStr), 0644); err != nil {
-		return nil, fmt.Errorf("将进程添加到CPU cgroup失败: %w", err)
-	}
-	
-	// 添加到pids控制器
-	pidsTasksPath := filepath.Join(pidsCgroupPath, "tasks")
-	if err := os.WriteFile(pidsTasksPath, []byte(pidStr), 0644); err != nil {
-		return nil, fmt.Errorf("将进程添加到pids cgroup失败: %w", err)
-	}
-
-	manager.BasePath = "/sys/fs/cgroup"
-	manager.Initialized = true
-	
 	return manager, nil
 }
 
 // setupCgroupsV2 配置cgroup v2资源限制
-func setupCgroupsV2(cgroupID string, pid int, profile *SecurityProfile) (*CgroupManager, error) {
+func setupCgroupsV2(cgroupID string, profile *SecurityProfile) (*CgroupManager, error) {
 	manager := &CgroupManager{
-		GroupID: cgroupID,
+		BasePath: "/sys/fs/cgroup",
+		GroupID:  cgroupID,
+		Version:  2,
 	}
 
-	// cgroup v2的基础路径
-	cgroupPath := filepath.Join("/sys/fs/cgroup", "croj", cgroupID)
-	if err := os.MkdirAll(cgroupPath, 0755); err != nil {
-		return nil, fmt.Errorf("创建cgroup v2目录失败: %w", err)
+	// 在父cgroup中启用必要的控制器（v2中带有子控制器的cgroup不能直接容纳进程，
+	// 所以只能在父级 croj 上启用，叶子cgroup用于放置进程）
+	parentPath := filepath.Join("/sys/fs/cgroup", "croj")
+	if err := os.MkdirAll(parentPath, 0755); err != nil {
+		return manager, fmt.Errorf("创建cgroup v2目录失败: %w", err)
 	}
-
-	// 启用必要的控制器
-	controllersPath := filepath.Join(cgroupPath, "cgroup.subtree_control")
+	rootControl := filepath.Join("/sys/fs/cgroup", "cgroup.subtree_control")
+	if err := os.WriteFile(rootControl, []byte("+memory +cpu +pids"), 0644); err != nil {
+		util.WarnLog("在根cgroup启用控制器失败: %v", err)
+	}
+	controllersPath := filepath.Join(parentPath, "cgroup.subtree_control")
 	if err := os.WriteFile(controllersPath, []byte("+memory +cpu +pids"), 0644); err != nil {
-		return nil, fmt.Errorf("启用cgroup控制器失败: %w", err)
+		return manager, fmt.Errorf("启用cgroup控制器失败: %w", err)
+	}
+
+	// cgroup v2的基础路径
+	cgroupPath := filepath.Join(parentPath, cgroupID)
+	if err := os.Mkdir(cgroupPath, 0755); err != nil {
+		return manager, fmt.Errorf("创建cgroup v2目录失败: %w", err)
 	}
+	manager.Paths = append(manager.Paths, cgroupPath)
+	manager.CPUAcctPath = cgroupPath
 
 	// 设置内存限制
 	if profile.MemoryLimitBytes > 0 {
 		memLimitPath := filepath.Join(cgroupPath, "memory.max")
 		if err := os.WriteFile(memLimitPath, []byte(fmt.Sprintf("%d", profile.MemoryLimitBytes)), 0644); err != nil {
-			return nil, fmt.Errorf("设置内存限制失败: %w", err)
+			return manager, fmt.Errorf("设置内存限制失败: %w", err)
 		}
-		
+
 		// 禁用内存交换
 		swapLimitPath := filepath.Join(cgroupPath, "memory.swap.max")
 		if err := os.WriteFile(swapLimitPath, []byte("0"), 0644); err != nil {
@@ -193,7 +440,7 @@ func setupCgroupsV2(cgroupID string, pid int, profile *SecurityProfile) (*Cgroup
 		cpuQuota := profile.CPULimit * 1000
 		cpuMaxPath := filepath.Join(cgroupPath, "cpu.max")
 		if err := os.WriteFile(cpuMaxPath, []byte(fmt.Sprintf("%d 100000", cpuQuota)), 0644); err != nil {
-			return nil, fmt.Errorf("设置CPU限制失败: %w", err)
+			return manager, fmt.Errorf("设置CPU限制失败: %w", err)
 		}
 	}
 
@@ -201,48 +448,35 @@ func setupCgroupsV2(cgroupID string, pid int, profile *SecurityProfile) (*Cgroup
 	if profile.PidsLimit > 0 {
 		pidsMaxPath := filepath.Join(cgroupPath, "pids.max")
 		if err := os.WriteFile(pidsMaxPath, []byte(fmt.Sprintf("%d", profile.PidsLimit)), 0644); err != nil {
-			return nil, fmt.Errorf("设置进程数限制失败: %w", err)
+			return manager, fmt.Errorf("设置进程数限制失败: %w", err)
 		}
 	}
 
-	// 将进程加入到cgroup
-	procsPath := filepath.Join(cgroupPath, "cgroup.procs")
-	if err := os.WriteFile(procsPath, []byte(strconv.Itoa(pid)), 0644); err != nil {
-		return nil, fmt.Errorf("将进程添加到cgroup失败: %w", err)
-	}
-
-	manager.BasePath = "/sys/fs/cgroup"
-	manager.Initialized = true
-	
 	return manager, nil
 }
 
 // cleanupCgroupV1 清理cgroup v1资源
 func cleanupCgroupV1(manager *CgroupManager) error {
 	// 在V1中，需要分别清理各个子系统
-	controllers := []string{"memory", "cpu", "pids"}
-	
-	for _, controller := range controllers {
-		cgroupPath := filepath.Join("/sys/fs/cgroup", controller, "croj", manager.GroupID)
-		
-		// 尝试删除目录
-		if err := os.RemoveAll(cgroupPath); err != nil {
-			util.WarnLog("清理cgroup控制器目录失败 %s: %v", controller, err)
+	for _, cgroupPath := range manager.Paths {
+		// cgroup目录只能用rmdir删除；目录已不存在说明被重复清理，同样报告
+		if err := os.Remove(cgroupPath); err != nil {
+			util.WarnLog("清理cgroup控制器目录失败 %s: %v", cgroupPath, err)
 		}
 	}
-	
+
 	return nil
 }
 
 // cleanupCgroupV2 清理cgroup v2资源
 func cleanupCgroupV2(manager *CgroupManager) error {
 	// V2只需要删除一个目录
-	cgroupPath := filepath.Join("/sys/fs/cgroup", "croj", manager.GroupID)
-	
-	// 尝试删除目录
-	if err := os.RemoveAll(cgroupPath); err != nil {
-		return fmt.Errorf("清理cgroup v2目录失败: %w", err)
+	for _, cgroupPath := range manager.Paths {
+		// 尝试删除目录，目录已不存在说明被重复清理
+		if err := os.Remove(cgroupPath); err != nil {
+			return fmt.Errorf("清理cgroup v2目录失败: %w", err)
+		}
 	}
-	
+
 	return nil
 }
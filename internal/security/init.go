@@ -0,0 +1,346 @@
+package security
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/CodeRushOJ/croj-sandbox/internal/util"
+)
+
+// 沙箱进程通过重新执行当前程序（初始化助手）启动：
+// 助手在 execve 用户程序之前加入cgroup、设置rlimit、no_new_privs 并加载seccomp过滤器，
+// 这样用户程序从第一条指令开始就处于全部限制之下，不存在启动后再施加限制的时间窗口
+//
+// 使用PID命名空间时，助手是命名空间的init进程。内核不会向init进程投递它没有处理的信号
+// （如SIGXFSZ、SIGXCPU、abort发出的SIGABRT），所以助手不直接执行用户程序，
+// 而是通过 /proc/self/exe 启动第二阶段助手，由它应用剩余的限制后执行用户程序，
+// init进程等待其退出，并通过状态管道把真实的等待状态报告给父进程
+
+// initConfigEnv 传递助手配置的环境变量
+const initConfigEnv = "_CROJ_SANDBOX_INIT"
+
+// InitFailureExitCode 初始化助手失败时的退出码，此时 stderr 以 InitErrorPrefix 开头
+const InitFailureExitCode = 125
+
+// InitErrorPrefix 初始化助手错误信息的前缀
+const InitErrorPrefix = "croj-sandbox init: "
+
+// statusFD PID命名空间的init进程报告用户程序等待状态的管道写端
+const statusFD = 3
+
+// Rlimit 子进程的资源限制 (setrlimit)
+type Rlimit struct {
+	Resource int    `json:"resource"`
+	Cur      uint64 `json:"cur"`
+	Max      uint64 `json:"max"`
+}
+
+// initConfig 父进程传给初始化助手的配置
+type initConfig struct {
+	Path        string           `json:"path"`        // 用户程序绝对路径
+	Args        []string         `json:"args"`        // 用户程序参数（含argv[0]）
+	Env         []string         `json:"env"`         // 用户程序环境变量
+	CgroupProcs []string         `json:"cgroupProcs"` // 需要写入的 cgroup.procs 文件
+	CPU         int              `json:"cpu"`         // 绑定的CPU核心 (-1 = 不绑定)
+	WorkDir     string           `json:"workDir"`     // 工作目录
+	RootDir     string           `json:"rootDir"`     // 新根文件系统挂载点，为空时不切换根目录
+	UID         int              `json:"uid"`         // 切换到的UID (-1 = 不切换)
+	GID         int              `json:"gid"`         // 切换到的GID (-1 = 不切换)
+	Rlimits     []Rlimit         `json:"rlimits"`
+	Profile     *SecurityProfile `json:"profile"`
+	Child       bool             `json:"child"` // 由PID命名空间的init进程启动的第二阶段，命名空间和根文件系统已就绪
+}
+
+func init() {
+	data, ok := os.LookupEnv(initConfigEnv)
+	if !ok {
+		return
+	}
+	// init 函数运行在主线程上，锁定后 execve 由主线程发起
+	runtime.LockOSThread()
+	err := runInit(data)
+	fmt.Fprintf(os.Stderr, "%s%v\n", InitErrorPrefix, err)
+	os.Exit(InitFailureExitCode)
+}
+
+// Command 返回在本沙箱中运行 name 的命令，env 为用户程序的环境变量（nil 表示继承当前环境）
+// 命令实际启动的是初始化助手，由助手在应用全部限制后 execve 用户程序
+func (s *Sandbox) Command(ctx context.Context, name string, args []string, env []string) (*exec.Cmd, error) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return nil, err
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("获取初始化助手路径失败: %w", err)
+	}
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd := exec.CommandContext(ctx, self)
+	if err := s.configureNamespaces(cmd); err != nil {
+		return nil, err
+	}
+	// 用户程序及其子进程位于独立的进程组，超时时可以整组终止
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+
+	rlimits := s.rlimits()
+	if s.Profile.hasNamespace(NamespacePID) {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return nil, fmt.Errorf("创建状态管道失败: %w", err)
+		}
+		s.statusReader, s.statusWriter = r, w
+		cmd.ExtraFiles = []*os.File{w} // statusFD
+	}
+
+	cfg := initConfig{
+		Path:    path,
+		Args:    append([]string{name}, args...),
+		Env:     env,
+		CPU:     s.CPU,
+		WorkDir: s.WorkDir,
+		RootDir: s.rootDir,
+		UID:     s.UID,
+		GID:     s.GID,
+		Rlimits: rlimits,
+		Profile: s.Profile,
+	}
+	if s.Cgroup != nil {
+		cfg.CgroupProcs = s.Cgroup.ProcsFiles()
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("序列化初始化助手配置失败: %w", err)
+	}
+
+	cmd.Args = []string{name}
+	cmd.Env = []string{initConfigEnv + "=" + string(data)}
+	cmd.Dir = s.WorkDir
+	return cmd, nil
+}
+
+// rlimInfinity RLIM_INFINITY，表示不限制
+const rlimInfinity = ^uint64(0)
+
+// rlimits 返回在 execve 之前为用户程序设置的资源限制
+func (s *Sandbox) rlimits() []Rlimit {
+	p := s.Profile
+	rlimits := []Rlimit{{Resource: syscall.RLIMIT_CORE}} // 禁止生成core文件
+	if p.CPUTimeLimitSec > 0 {
+		// 软限制发送SIGXCPU，硬限制再多一秒后发送SIGKILL
+		cpu := uint64(p.CPUTimeLimitSec)
+		rlimits = append(rlimits, Rlimit{Resource: syscall.RLIMIT_CPU, Cur: cpu, Max: cpu + 1})
+	}
+	if p.StackLimitBytes != 0 {
+		rlimits = append(rlimits, fixedRlimit(syscall.RLIMIT_STACK, p.StackLimitBytes))
+	}
+	if p.FileSizeLimitBytes > 0 {
+		rlimits = append(rlimits, fixedRlimit(syscall.RLIMIT_FSIZE, p.FileSizeLimitBytes))
+	}
+	if p.OpenFilesLimit > 0 {
+		rlimits = append(rlimits, fixedRlimit(syscall.RLIMIT_NOFILE, int64(p.OpenFilesLimit)))
+	}
+	if p.PidsLimit > 0 && s.UID > 0 {
+		// RLIMIT_NPROC 按用户统计，只有用户程序使用独占的UID时才不会受其他进程影响
+		rlimits = append(rlimits, fixedRlimit(rlimitNproc, int64(p.PidsLimit)))
+	}
+	return rlimits
+}
+
+// rlimitNproc RLIMIT_NPROC，syscall 包没有导出
+const rlimitNproc = 6
+
+// fixedRlimit 返回软硬限制相同的资源限制，负数表示不限制
+// 子进程在新的用户命名空间中无法提高硬限制，因此不超过当前进程的硬限制
+func fixedRlimit(resource int, value int64) Rlimit {
+	limit := uint64(value)
+	if value < 0 {
+		limit = rlimInfinity
+	}
+	var current syscall.Rlimit
+	if err := syscall.Getrlimit(resource, &current); err == nil && limit > current.Max {
+		limit = current.Max
+	}
+	return Rlimit{Resource: resource, Cur: limit, Max: limit}
+}
+
+// runInit 在子进程中应用限制并执行用户程序，只在失败时返回
+func runInit(data string) error {
+	// 助手的日志会混入用户程序的 stderr
+	log.SetOutput(io.Discard)
+
+	var cfg initConfig
+	if err := json.Unmarshal([]byte(data), &cfg); err != nil {
+		return fmt.Errorf("解析配置失败: %w", err)
+	}
+
+	profile := cfg.Profile
+	if profile == nil {
+		profile = NewDefaultSecurityProfile()
+	}
+	cfg.Profile = profile
+
+	// cgroup、CPU绑定和命名空间由第一阶段设置，第二阶段继承
+	if !cfg.Child {
+		if err := joinCgroups(cfg.CgroupProcs, os.Getpid()); err != nil {
+			return err
+		}
+		if cfg.CPU >= 0 {
+			// pid 0 表示当前线程，即将执行 execve 的线程
+			if err := util.SetCPUAffinity(0, cfg.CPU); err != nil {
+				return err
+			}
+		}
+		if cfg.RootDir != "" {
+			if err := setupRootfs(&cfg); err != nil {
+				return err
+			}
+		}
+		if profile.hasNamespace(NamespaceUTS) {
+			if err := syscall.Sethostname([]byte(sandboxHostname)); err != nil {
+				return fmt.Errorf("设置主机名失败: %w", err)
+			}
+		}
+		if profile.hasNamespace(NamespacePID) {
+			return runNamespaceInit(&cfg)
+		}
+	}
+
+	for _, rl := range cfg.Rlimits {
+		limit := syscall.Rlimit{Cur: rl.Cur, Max: rl.Max}
+		if err := syscall.Setrlimit(rl.Resource, &limit); err != nil {
+			return fmt.Errorf("设置资源限制 %d 失败: %w", rl.Resource, err)
+		}
+	}
+
+	// 挂载完成后放弃root身份，同时清空附加组
+	if cfg.UID >= 0 {
+		if err := syscall.Setgroups([]int{}); err != nil {
+			return fmt.Errorf("清空附加组失败: %w", err)
+		}
+		if cfg.GID >= 0 {
+			if err := syscall.Setgid(cfg.GID); err != nil {
+				return fmt.Errorf("切换到GID %d 失败: %w", cfg.GID, err)
+			}
+		}
+		if err := syscall.Setuid(cfg.UID); err != nil {
+			return fmt.Errorf("切换到UID %d 失败: %w", cfg.UID, err)
+		}
+	}
+
+	// 准备 execve 参数，加载seccomp后不能再分配或打开文件
+	pathp, err := syscall.BytePtrFromString(cfg.Path)
+	if err != nil {
+		return err
+	}
+	argvp, err := syscall.SlicePtrFromStrings(cfg.Args)
+	if err != nil {
+		return err
+	}
+	envp, err := syscall.SlicePtrFromStrings(cfg.Env)
+	if err != nil {
+		return err
+	}
+
+	if profile.NoNewPrivileges {
+		if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno != 0 {
+			return fmt.Errorf("设置no_new_privs失败: %w", errno)
+		}
+	}
+	if profile.SeccompMode != "disabled" {
+		if err := ApplySeccompFilters(profile, uintptr(unsafe.Pointer(pathp))); err != nil {
+			return err
+		}
+	}
+
+	_, _, errno := syscall.RawSyscall(syscall.SYS_EXECVE,
+		uintptr(unsafe.Pointer(pathp)),
+		uintptr(unsafe.Pointer(&argvp[0])),
+		uintptr(unsafe.Pointer(&envp[0])))
+	runtime.KeepAlive(pathp)
+	runtime.KeepAlive(argvp)
+	runtime.KeepAlive(envp)
+	return fmt.Errorf("执行 %s 失败: %w", cfg.Path, errno)
+}
+
+// runNamespaceInit 作为PID命名空间的init进程运行：启动第二阶段助手并等待它（即用户程序）退出，
+// 把等待状态写入状态管道后以相同的退出码退出，只在失败时返回
+func runNamespaceInit(cfg *initConfig) error {
+	// 状态管道不能泄漏给用户程序
+	syscall.CloseOnExec(statusFD)
+	status := os.NewFile(statusFD, "status")
+
+	cfg.Child = true
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return fmt.Errorf("序列化第二阶段配置失败: %w", err)
+	}
+	// 旧的根已卸载，通过 /proc/self/exe 重新执行自身
+	pid, err := syscall.ForkExec("/proc/self/exe", cfg.Args[:1], &syscall.ProcAttr{
+		Env:   []string{initConfigEnv + "=" + string(data)},
+		Files: []uintptr{0, 1, 2},
+	})
+	if err != nil {
+		return fmt.Errorf("启动第二阶段失败: %w", err)
+	}
+
+	// 同时回收被重新指定父进程的孤儿进程，用户程序退出后init退出，命名空间中剩余的进程随之被终止
+	var ws syscall.WaitStatus
+	for {
+		wpid, err := syscall.Wait4(-1, &ws, 0, nil)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("等待用户程序失败: %w", err)
+		}
+		if wpid == pid {
+			break
+		}
+	}
+
+	fmt.Fprintf(status, "%d\n", uint32(ws))
+	status.Close()
+	if ws.Signaled() {
+		os.Exit(128 + int(ws.Signal()))
+	}
+	os.Exit(ws.ExitStatus())
+	return nil
+}
+
+// ExitStatus 返回用户程序的等待状态，应在命令的 Wait 返回之后调用
+// 使用PID命名空间时命令启动的是命名空间的init进程，其退出状态无法反映用户程序是否被信号终止，
+// 真实状态由init进程通过状态管道报告；ok 为false时调用方应使用命令自身的状态
+func (s *Sandbox) ExitStatus() (status syscall.WaitStatus, ok bool) {
+	if s == nil || s.statusReader == nil {
+		return 0, false
+	}
+	// init进程已经退出，关闭父进程持有的写端后读到EOF
+	s.statusWriter.Close()
+	data, err := io.ReadAll(s.statusReader)
+	if err != nil {
+		return 0, false
+	}
+	value, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return syscall.WaitStatus(value), true
+}
+
+// prSetNoNewPrivs prctl(PR_SET_NO_NEW_PRIVS)
+const prSetNoNewPrivs = 38
@@ -0,0 +1,310 @@
+package security
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+)
+
+// 可隔离的命名空间，用于 SecurityProfile.Namespaces
+const (
+	NamespacePID   = "pid"   // 独立的进程号空间，用户程序看不到沙箱外的进程
+	NamespaceMount = "mount" // 独立的挂载点，用户程序只能看到最小化的根文件系统
+	NamespaceNet   = "net"   // 独立的网络栈，只有未启用的回环接口
+	NamespaceIPC   = "ipc"   // 独立的System V IPC和POSIX消息队列
+	NamespaceUTS   = "uts"   // 独立的主机名
+	NamespaceUser  = "user"  // 独立的用户映射，沙箱内的root在宿主机上没有额外权限
+)
+
+// sandboxHostname 沙箱内的主机名
+const sandboxHostname = "croj-sandbox"
+
+// rootfsTmpfsOptions 根文件系统和可写目录使用的tmpfs选项
+const (
+	rootfsTmpfsOptions   = "size=1m,mode=755"
+	writableTmpfsOptions = "size=64m,mode=1777"
+)
+
+// sandboxDevices 绑定到沙箱 /dev 下的设备文件
+var sandboxDevices = []string{"/dev/null", "/dev/zero", "/dev/full", "/dev/random", "/dev/urandom"}
+
+// cloneFlags 返回创建指定命名空间所需的clone标志
+func cloneFlags(namespaces []string) (uintptr, error) {
+	var flags uintptr
+	for _, ns := range namespaces {
+		switch ns {
+		case NamespacePID:
+			flags |= syscall.CLONE_NEWPID
+		case NamespaceMount:
+			flags |= syscall.CLONE_NEWNS
+		case NamespaceNet:
+			flags |= syscall.CLONE_NEWNET
+		case NamespaceIPC:
+			flags |= syscall.CLONE_NEWIPC
+		case NamespaceUTS:
+			flags |= syscall.CLONE_NEWUTS
+		case NamespaceUser:
+			flags |= syscall.CLONE_NEWUSER
+		default:
+			return 0, fmt.Errorf("未知的命名空间: %s", ns)
+		}
+	}
+	return flags, nil
+}
+
+// hasNamespace 检查profile是否要求创建指定命名空间
+func (p *SecurityProfile) hasNamespace(ns string) bool {
+	for _, n := range p.Namespaces {
+		if n == ns {
+			return true
+		}
+	}
+	return false
+}
+
+// configureNamespaces 设置命令的命名空间，需要新的挂载命名空间时创建根文件系统挂载点
+func (s *Sandbox) configureNamespaces(cmd *exec.Cmd) error {
+	flags, err := cloneFlags(s.Profile.Namespaces)
+	if err != nil || flags == 0 {
+		return err
+	}
+
+	attr := &syscall.SysProcAttr{Cloneflags: flags}
+	if flags&syscall.CLONE_NEWUSER != 0 {
+		// 沙箱内的root映射为当前用户，不授予宿主机上的任何额外权限
+		attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}}
+		attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}}
+		// 运行用户程序的UID/GID在命名空间内外保持一致，以便访问属于它的工作目录
+		if s.UID > 0 && s.UID != os.Getuid() {
+			attr.UidMappings = append(attr.UidMappings, syscall.SysProcIDMap{ContainerID: s.UID, HostID: s.UID, Size: 1})
+		}
+		if s.GID > 0 && s.GID != os.Getgid() {
+			attr.GidMappings = append(attr.GidMappings, syscall.SysProcIDMap{ContainerID: s.GID, HostID: s.GID, Size: 1})
+		}
+		attr.GidMappingsEnableSetgroups = os.Getuid() == 0
+	}
+	cmd.SysProcAttr = attr
+
+	if flags&syscall.CLONE_NEWNS != 0 && s.rootDir == "" {
+		rootDir, err := os.MkdirTemp("", "croj-rootfs-")
+		if err != nil {
+			return fmt.Errorf("创建根文件系统目录失败: %w", err)
+		}
+		s.rootDir = rootDir
+	}
+	return nil
+}
+
+// setupRootfs 在子进程中构建最小化的根文件系统并切换进去：
+// 只读路径以只读绑定挂载，工作目录以读写绑定挂载，可写路径使用tmpfs，隐藏路径被遮盖
+func setupRootfs(cfg *initConfig) error {
+	profile := cfg.Profile
+	root := cfg.RootDir
+
+	// 挂载事件不传播回宿主机
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("设置挂载传播失败: %w", err)
+	}
+	if err := syscall.Mount("tmpfs", root, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, rootfsTmpfsOptions); err != nil {
+		return fmt.Errorf("挂载根文件系统失败: %w", err)
+	}
+
+	for _, pattern := range profile.ReadOnlyPaths {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("无效的只读路径 %s: %w", pattern, err)
+		}
+		for _, path := range matches {
+			if err := bindMount(root, path, true); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, path := range profile.WritablePaths {
+		target := filepath.Join(root, path)
+		if err := os.MkdirAll(target, 0755); err != nil {
+			return fmt.Errorf("创建可写目录 %s 失败: %w", path, err)
+		}
+		if err := syscall.Mount("tmpfs", target, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, writableTmpfsOptions); err != nil {
+			return fmt.Errorf("挂载可写目录 %s 失败: %w", path, err)
+		}
+	}
+
+	// 工作目录可能位于可写路径下（如 /tmp），必须在tmpfs之后挂载
+	if cfg.WorkDir != "" {
+		if err := bindMount(root, cfg.WorkDir, false); err != nil {
+			return err
+		}
+	}
+
+	if err := setupDev(root); err != nil {
+		return err
+	}
+
+	if profile.hasNamespace(NamespacePID) {
+		target := filepath.Join(root, "proc")
+		if err := os.MkdirAll(target, 0555); err != nil {
+			return fmt.Errorf("创建 /proc 失败: %w", err)
+		}
+		if err := syscall.Mount("proc", target, "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, ""); err != nil {
+			return fmt.Errorf("挂载 /proc 失败: %w", err)
+		}
+	}
+
+	for _, path := range profile.HiddenPaths {
+		if err := maskPath(root, path); err != nil {
+			return err
+		}
+	}
+
+	// 切换根目录并卸载旧的根
+	if err := os.Chdir(root); err != nil {
+		return fmt.Errorf("进入根文件系统失败: %w", err)
+	}
+	if err := syscall.PivotRoot(".", "."); err != nil {
+		return fmt.Errorf("切换根文件系统失败: %w", err)
+	}
+	if err := syscall.Unmount(".", syscall.MNT_DETACH); err != nil {
+		return fmt.Errorf("卸载旧的根文件系统失败: %w", err)
+	}
+	if err := syscall.Mount("", "/", "", syscall.MS_REMOUNT|syscall.MS_RDONLY|syscall.MS_NOSUID|syscall.MS_NODEV, rootfsTmpfsOptions); err != nil {
+		return fmt.Errorf("将根文件系统设为只读失败: %w", err)
+	}
+
+	dir := cfg.WorkDir
+	if dir == "" {
+		dir = "/"
+	}
+	if err := os.Chdir(dir); err != nil {
+		return fmt.Errorf("进入工作目录失败: %w", err)
+	}
+	return nil
+}
+
+// bindMount 将宿主机路径绑定到新根下的相同位置，符号链接按原样重建
+func bindMount(root, path string, readOnly bool) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("访问 %s 失败: %w", path, err)
+	}
+
+	target := filepath.Join(root, path)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("创建挂载点 %s 失败: %w", path, err)
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		// 例如 /lib -> usr/lib，链接目标本身也应在只读路径中
+		link, err := os.Readlink(path)
+		if err != nil {
+			return fmt.Errorf("读取符号链接 %s 失败: %w", path, err)
+		}
+		if err := os.Symlink(link, target); err != nil && !os.IsExist(err) {
+			return fmt.Errorf("创建符号链接 %s 失败: %w", path, err)
+		}
+		return nil
+	}
+	if err := createMountPoint(target, info.IsDir()); err != nil {
+		return err
+	}
+
+	if err := syscall.Mount(path, target, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("绑定挂载 %s 失败: %w", path, err)
+	}
+	if !readOnly {
+		return nil
+	}
+
+	// 重新挂载为只读时必须保留原挂载的锁定标志，否则在用户命名空间中会失败
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return fmt.Errorf("读取 %s 的挂载标志失败: %w", path, err)
+	}
+	flags := uintptr(syscall.MS_BIND | syscall.MS_REMOUNT | syscall.MS_RDONLY)
+	for stFlag, msFlag := range lockedMountFlags {
+		if st.Flags&stFlag != 0 {
+			flags |= msFlag
+		}
+	}
+	if err := syscall.Mount("", target, "", flags, ""); err != nil {
+		return fmt.Errorf("将 %s 设为只读失败: %w", path, err)
+	}
+	return nil
+}
+
+// lockedMountFlags statfs 返回的 ST_* 标志与对应的 MS_* 挂载标志
+var lockedMountFlags = map[int64]uintptr{
+	0x2:    syscall.MS_NOSUID,     // ST_NOSUID
+	0x4:    syscall.MS_NODEV,      // ST_NODEV
+	0x8:    syscall.MS_NOEXEC,     // ST_NOEXEC
+	0x400:  syscall.MS_NOATIME,    // ST_NOATIME
+	0x800:  syscall.MS_NODIRATIME, // ST_NODIRATIME
+	0x1000: syscall.MS_RELATIME,   // ST_RELATIME
+}
+
+// createMountPoint 创建用作挂载点的空目录或空文件
+func createMountPoint(target string, dir bool) error {
+	if dir {
+		if err := os.MkdirAll(target, 0755); err != nil {
+			return fmt.Errorf("创建挂载点 %s 失败: %w", target, err)
+		}
+		return nil
+	}
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_RDONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("创建挂载点 %s 失败: %w", target, err)
+	}
+	return f.Close()
+}
+
+// setupDev 创建只包含常用设备的 /dev
+func setupDev(root string) error {
+	dev := filepath.Join(root, "dev")
+	if err := os.MkdirAll(dev, 0755); err != nil {
+		return fmt.Errorf("创建 /dev 失败: %w", err)
+	}
+	if err := syscall.Mount("tmpfs", dev, "tmpfs", syscall.MS_NOSUID|syscall.MS_NOEXEC, rootfsTmpfsOptions); err != nil {
+		return fmt.Errorf("挂载 /dev 失败: %w", err)
+	}
+	for _, device := range sandboxDevices {
+		if err := bindMount(root, device, false); err != nil {
+			return err
+		}
+	}
+	links := map[string]string{
+		"fd": "/proc/self/fd", "stdin": "/proc/self/fd/0",
+		"stdout": "/proc/self/fd/1", "stderr": "/proc/self/fd/2",
+	}
+	for name, link := range links {
+		if err := os.Symlink(link, filepath.Join(dev, name)); err != nil {
+			return fmt.Errorf("创建 /dev/%s 失败: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// maskPath 遮盖新根下已存在的路径：目录用空的只读tmpfs，文件用 /dev/null
+func maskPath(root, path string) error {
+	target := filepath.Join(root, path)
+	info, err := os.Stat(target)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("访问隐藏路径 %s 失败: %w", path, err)
+	}
+	if info.IsDir() {
+		err = syscall.Mount("tmpfs", target, "tmpfs", syscall.MS_RDONLY|syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, "mode=000")
+	} else {
+		err = syscall.Mount(filepath.Join(root, "dev/null"), target, "", syscall.MS_BIND, "")
+	}
+	if err != nil {
+		return fmt.Errorf("隐藏路径 %s 失败: %w", path, err)
+	}
+	return nil
+}
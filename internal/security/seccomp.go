@@ -2,33 +2,35 @@ package security
 
 import (
 	"fmt"
-	"log"
+	"syscall"
 
-	"github.com/seccomp/libseccomp-golang"
 	"github.com/CodeRushOJ/croj-sandbox/internal/util"
+	"github.com/seccomp/libseccomp-golang"
 )
 
-// ApplySeccompFilters 应用seccomp系统调用过滤器
-func ApplySeccompFilters(profile *SecurityProfile) error {
+// ApplySeccompFilters 为当前进程应用seccomp系统调用过滤器，由初始化助手在 execve 之前调用
+// execPath: 助手即将传给 execve 的路径指针，禁止执行其他程序时只放行这一次 execve
+func ApplySeccompFilters(profile *SecurityProfile, execPath uintptr) error {
 	// 默认设置为拒绝所有系统调用
-	defaultAction := seccomp.ActErrno
+	defaultAction := seccomp.ActErrno.SetReturnCode(int16(syscall.EPERM))
 	if profile.SeccompMode == "strict" {
-		defaultAction = seccomp.ActKill // 更严格的模式：直接终止进程
+		// 更严格的模式：直接终止整个进程（而不只是调用的线程），等待状态为SIGSYS
+		defaultAction = seccomp.ActKillProcess
 	}
-	
+
 	// 创建seccomp过滤器
 	filter, err := seccomp.NewFilter(defaultAction)
 	if err != nil {
 		return fmt.Errorf("创建seccomp过滤器失败: %w", err)
 	}
-	
+
 	// 允许的系统调用列表
 	allowedSyscalls := profile.AllowedSyscalls
 	if len(allowedSyscalls) == 0 {
 		// 如果未指定，使用默认安全列表
 		allowedSyscalls = GetDefaultAllowedSyscalls()
 	}
-	
+
 	// 添加白名单系统调用
 	for _, syscallName := range allowedSyscalls {
 		syscallID, err := seccomp.GetSyscallFromName(syscallName)
@@ -36,12 +38,12 @@ func ApplySeccompFilters(profile *SecurityProfile) error {
 			util.WarnLog("系统调用不存在: %s (忽略)", syscallName)
 			continue
 		}
-		
+
 		if err := filter.AddRule(syscallID, seccomp.ActAllow); err != nil {
 			util.WarnLog("添加系统调用规则失败 %s: %v", syscallName, err)
 		}
 	}
-	
+
 	// 特殊处理：添加对socket系统调用的精细控制
 	if profile.DisableNetwork {
 		// 允许socket但有条件限制
@@ -60,7 +62,7 @@ func ApplySeccompFilters(profile *SecurityProfile) error {
 					},
 				},
 			)
-			
+
 			filter.AddRuleConditional(
 				socketCall,
 				seccomp.ActErrno,
@@ -75,25 +77,39 @@ func ApplySeccompFilters(profile *SecurityProfile) error {
 			)
 		}
 	}
-	
-	// 如果禁止执行其他程序
-	if profile.DisableExec {
-		execveSyscall, err := seccomp.GetSyscallFromName("execve")
-		if err == nil {
-			filter.AddRule(execveSyscall, seccomp.ActErrno)
+
+	// 如果禁止执行其他程序，只允许助手用 execPath 启动用户程序
+	// seccomp只能比较指针值而不能检查路径内容，这是在 execve 前加载过滤器所能做到的限制
+	execveSyscall, err := seccomp.GetSyscallFromName("execve")
+	if err == nil {
+		if profile.DisableExec {
+			filter.AddRuleConditional(
+				execveSyscall,
+				seccomp.ActAllow,
+				[]seccomp.ScmpCondition{
+					{
+						Argument: 0,
+						Op:       seccomp.CompareEqual,
+						Operand1: uint64(execPath),
+					},
+				},
+			)
+		} else {
+			filter.AddRule(execveSyscall, seccomp.ActAllow)
 		}
-		
+	}
+	if !profile.DisableExec {
 		execveatSyscall, err := seccomp.GetSyscallFromName("execveat")
 		if err == nil {
-			filter.AddRule(execveatSyscall, seccomp.ActErrno)
+			filter.AddRule(execveatSyscall, seccomp.ActAllow)
 		}
 	}
-	
+
 	// 加载seccomp过滤器
 	if err := filter.Load(); err != nil {
 		return fmt.Errorf("加载seccomp过滤器失败: %w", err)
 	}
-	
+
 	return nil
 }
 
@@ -104,29 +120,36 @@ func GetDefaultAllowedSyscalls() []string {
 		// 常规I/O操作
 		"read", "write", "close", "fstat", "lseek", "mmap", "mprotect", "munmap", "brk",
 		"readv", "writev", "pread64", "pwrite64", "lstat", "readlink",
-		
+
 		// 文件操作
 		"access", "open", "openat", "stat", "getcwd", "fcntl",
-		"fstatfs", "getdents", "getdents64", "readdir", "rename", "unlink", "rmdir", 
+		"newfstatat", "statx", "faccessat", "faccessat2", "readlinkat", "ioctl", "ftruncate", "fsync",
+		"unlinkat", "renameat", "mkdirat", "fchmod", "fchdir", "dup3", "pipe2", "close_range",
+		"fstatfs", "getdents", "getdents64", "readdir", "rename", "unlink", "rmdir",
 		"mkdir", "link", "chmod", "truncate", "fallocate", "utime", "chdir", "dup", "dup2", "pipe",
-		
+
 		// 进程管理
-		"clone", "fork", "vfork", "wait4", "kill", "exit", "exit_group", 
+		"clone", "fork", "vfork", "wait4", "kill", "exit", "exit_group",
 		"rt_sigreturn", "rt_sigaction", "rt_sigprocmask", "rt_sigqueueinfo",
 		"setitimer", "getitimer", "nanosleep", "clock_gettime", "sched_yield",
-		
+		"clone3", "arch_prctl", "set_tid_address", "set_robust_list", "rseq", "sigaltstack",
+		"tgkill", "tkill", "restart_syscall", "clock_nanosleep", "clock_getres", "sched_getaffinity",
+
 		// 内存管理
 		"mremap", "msync", "mincore", "madvise", "shmget", "shmat", "shmdt", "shmctl",
-		
+		"membarrier",
+
 		// 资源信息
 		"getrusage", "getrlimit", "getpriority", "getuid", "geteuid", "getgid", "getegid",
 		"gettid", "getpid", "getppid", "gettimeofday", "uname", "getrandom",
-		
+		"prlimit64", "sysinfo", "getresuid", "getresgid", "getgroups", "getpgrp",
+
 		// 套接字（受条件控制）
 		"socket", "socketpair", "bind", "listen", "accept", "accept4", "connect",
-		
+
 		// 其他必要调用
 		"futex", "epoll_create", "epoll_create1", "epoll_ctl", "epoll_wait", "epoll_pwait",
 		"select", "poll", "timerfd_create", "timerfd_settime", "timerfd_gettime",
+		"pselect6", "ppoll", "eventfd2",
 	}
 }
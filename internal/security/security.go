@@ -1,42 +1,77 @@
 package security
 
 import (
+	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
-	
+	"sync"
+
 	"github.com/CodeRushOJ/croj-sandbox/internal/util"
+	"github.com/google/uuid"
 )
 
 // SecurityProfile 定义进程安全配置
 type SecurityProfile struct {
 	// Seccomp相关设置
-	SeccompMode       string   // seccomp模式: strict, filtered, disabled
-	AllowedSyscalls   []string // 允许的系统调用白名单
-	BlockedSyscalls   []string // 拒绝的系统调用黑名单
-	
+	SeccompMode     string   // seccomp模式: strict, filtered, disabled
+	AllowedSyscalls []string // 允许的系统调用白名单
+	BlockedSyscalls []string // 拒绝的系统调用黑名单
+
 	// Cgroups相关设置
-	EnableCgroups     bool     // 是否启用cgroups
-	MemoryLimitBytes  int64    // 内存限制 (字节)
-	CPULimit          int      // CPU限制 (%)
-	PidsLimit         int      // 最大进程/线程数
-	
+	EnableCgroups    bool  // 是否启用cgroups
+	MemoryLimitBytes int64 // 内存限制 (字节)
+	CPULimit         int   // CPU限制 (%)
+	PidsLimit        int   // 最大进程/线程数，使用独占UID时同时设置 RLIMIT_NPROC
+	CPUTimeLimitSec  int   // CPU时间上限（秒，RLIMIT_CPU），超出后进程被内核终止（0 = 不限制）
+
+	// 资源限制 (setrlimit)，在 execve 之前应用，core文件始终禁用
+	StackLimitBytes    int64 // 栈大小上限（字节，RLIMIT_STACK），-1 = 不限制，0 = 继承
+	FileSizeLimitBytes int64 // 单个文件可写入的大小上限（字节，RLIMIT_FSIZE），超出时进程收到SIGXFSZ（0 = 继承）
+	OpenFilesLimit     int   // 打开的文件描述符数上限（RLIMIT_NOFILE，0 = 继承）
+
 	// 网络和文件系统限制
-	DisableNetwork    bool     // 禁用所有网络访问
-	ReadOnlyPaths     []string // 只读目录列表
-	WritablePaths     []string // 可写目录列表
-	HiddenPaths       []string // 对进程隐藏的路径
-	
+	DisableNetwork bool     // 禁用所有网络访问
+	ReadOnlyPaths  []string // 只读目录列表
+	WritablePaths  []string // 可写目录列表
+	HiddenPaths    []string // 对进程隐藏的路径
+	Namespaces     []string // 需要隔离的命名空间 (Namespace* 常量)，包含mount时以上路径规则生效
+
 	// 其他安全选项
-	NoNewPrivileges   bool     // 防止获取新权限
-	DisableExec       bool     // 禁止执行其他程序
+	NoNewPrivileges bool // 防止获取新权限
+	DisableExec     bool // 禁止执行其他程序
 }
 
 // CgroupManager 管理cgroup资源
 type CgroupManager struct {
-	BasePath    string // cgroup文件系统基础路径
-	GroupID     string // 当前cgroup组ID
-	Initialized bool   // 是否已初始化
+	BasePath    string   // cgroup文件系统基础路径
+	GroupID     string   // 当前cgroup组ID
+	Version     int      // cgroup版本 (1 或 2)
+	Paths       []string // 已创建的cgroup目录（v1每个控制器一个）
+	CPUAcctPath string   // 记录CPU使用量的cgroup目录，为空时不可用
+	FreezerPath string   // 用于冻结进程的cgroup目录，为空时不可用
+	Initialized bool     // 是否已初始化
+}
+
+// Sandbox 单次执行的安全上下文（cgroup、seccomp配置）
+// 由执行器持有，通过 Command 启动的进程在 execve 之前就已加入cgroup并加载seccomp过滤器
+// 执行结束后调用 Close 释放，多次调用 Close 只会清理一次
+type Sandbox struct {
+	Profile *SecurityProfile // 使用的安全配置
+	Cgroup  *CgroupManager   // 进程将加入的cgroup，未启用时为nil
+	CPU     int              // 进程绑定的CPU核心 (-1 = 不绑定)
+	WorkDir string           // 进程的工作目录，在新的根文件系统中以读写方式可见
+	UID     int              // 运行用户程序的UID (-1 = 不切换)
+	GID     int              // 运行用户程序的GID (-1 = 不切换)
+	rootDir string           // 新根文件系统的挂载点，由 Close 删除
+
+	// 使用PID命名空间时，init进程通过该管道报告用户程序的等待状态
+	statusReader *os.File
+	statusWriter *os.File
+
+	mu        sync.Mutex // 保证 Kill 不会与 Close 并发执行
+	closed    bool
+	closeOnce sync.Once
+	closeErr  error
 }
 
 // NewDefaultSecurityProfile 返回默认安全配置
@@ -44,14 +79,18 @@ func NewDefaultSecurityProfile() *SecurityProfile {
 	return &SecurityProfile{
 		SeccompMode:     "filtered",
 		EnableCgroups:   true,
-		PidsLimit:       64,        // 最多64个进程/线程
-		DisableNetwork:  true,      // 禁止网络访问
-		NoNewPrivileges: true,      // 禁止获取新权限
-		DisableExec:     true,      // 禁止运行其他程序
+		PidsLimit:       64,   // 最多64个进程/线程
+		DisableNetwork:  true, // 禁止网络访问
+		NoNewPrivileges: true, // 禁止获取新权限
+		DisableExec:     true, // 禁止运行其他程序
+		Namespaces: []string{
+			NamespacePID, NamespaceMount, NamespaceNet,
+			NamespaceIPC, NamespaceUTS, NamespaceUser,
+		},
 		ReadOnlyPaths: []string{
-			"/usr", "/lib", "/lib64", "/bin", "/sbin", 
-			"/etc/ssl", "/etc/passwd", "/etc/group", 
-			"/etc/resolv.conf",
+			"/usr", "/lib", "/lib64", "/bin", "/sbin",
+			"/etc/ssl", "/etc/passwd", "/etc/group",
+			"/etc/resolv.conf", "/etc/ld.so.cache", "/etc/alternatives",
 		},
 		WritablePaths: []string{
 			"/tmp",
@@ -66,89 +105,117 @@ func NewDefaultSecurityProfile() *SecurityProfile {
 // ProfileForLanguage 根据编程语言返回合适的安全配置
 func ProfileForLanguage(language string) *SecurityProfile {
 	profile := NewDefaultSecurityProfile()
-	
+
 	// 配置系统调用白名单
 	profile.AllowedSyscalls = GetDefaultAllowedSyscalls()
-	
+
 	// 根据语言特点调整配置
 	switch language {
 	case "python":
 		// Python需要创建更多子进程和动态加载库
 		profile.PidsLimit = 128
-		profile.ReadOnlyPaths = append(profile.ReadOnlyPaths, 
+		profile.ReadOnlyPaths = append(profile.ReadOnlyPaths,
 			"/usr/lib/python*", "/usr/local/lib/python*")
-	
+
 	case "java":
 		// Java需要更多资源和创建子进程的能力
 		profile.PidsLimit = 256
-		profile.ReadOnlyPaths = append(profile.ReadOnlyPaths, 
+		profile.ReadOnlyPaths = append(profile.ReadOnlyPaths,
 			"/usr/lib/jvm", "/etc/java*")
 
 	case "go":
 		// Go程序通常更加独立，可以应用更严格的限制
 		profile.SeccompMode = "strict"
 	}
-	
+
 	return profile
 }
 
-// SetupSecurity 设置所有安全机制
-func SetupSecurity(profile *SecurityProfile, pid int, runDir string) error {
-	// 创建唯一的cgroup ID
-	cgroupID := fmt.Sprintf("croj_sandbox_%d", pid)
-	
+// NewSandbox 为一次执行创建安全上下文：按配置创建cgroup，seccomp等限制在子进程中应用
+// 即使返回错误，已经创建的部分（如cgroup）也记录在返回的 Sandbox 中，调用方应始终调用 Close
+func NewSandbox(profile *SecurityProfile) (*Sandbox, error) {
+	sb := &Sandbox{Profile: profile, CPU: -1, UID: -1, GID: -1}
+
+	// 创建唯一的cgroup ID（同一进程内并发执行，追加随机后缀）
+	cgroupID := fmt.Sprintf("croj_sandbox_%d_%s", os.Getpid(), uuid.New().String()[:8])
+
 	// 设置cgroup资源限制
 	if profile.EnableCgroups {
-		manager, err := SetupCgroups(cgroupID, pid, profile)
+		manager, err := SetupCgroups(cgroupID, profile)
 		if err != nil {
 			util.ErrorLog("设置cgroup失败: %v", err)
-			return err
-		}
-		
-		// 保存cgroup管理器，以便后续清理
-		cgroupManager := manager
-		util.DebugLog("已设置cgroup限制: %s", cgroupID)
-		
-		// 注册清理函数
-		RegisterCleanupHandler(func() {
-			if err := CleanupCgroups(cgroupManager); err != nil {
-				util.ErrorLog("清理cgroup失败: %v", err)
-			}
-		})
-	}
-	
-	// 应用seccomp系统调用过滤
-	if profile.SeccompMode != "disabled" {
-		if err := ApplySeccompFilters(profile); err != nil {
-			util.ErrorLog("设置seccomp过滤器失败: %v", err)
-			return err
+			return sb, err
 		}
-		util.DebugLog("已应用seccomp过滤器, 模式: %s", profile.SeccompMode)
+
+		// 保存cgroup管理器，由 Close 清理
+		sb.Cgroup = manager
+		util.DebugLog("已创建cgroup: %s", cgroupID)
 	}
-	
-	return nil
+
+	return sb, nil
 }
 
-// CreateNamespace 创建隔离的命名空间
-func CreateNamespace(cmd *os.Process, profile *SecurityProfile) error {
-	// 在Linux上，我们可以为进程创建隔离的命名空间
-	// 这需要在进程开始前设置
-	return nil
+// CPUCgroup 返回记录进程CPU使用量的cgroup，没有时返回nil，nil 接收者安全
+func (s *Sandbox) CPUCgroup() *CgroupManager {
+	if s == nil || s.Cgroup == nil || s.Cgroup.CPUAcctPath == "" {
+		return nil
+	}
+	return s.Cgroup
 }
 
-// RegisterCleanupHandler 注册资源清理处理程序
-func RegisterCleanupHandler(handler func()) {
-	// 保存清理函数，以便在执行结束后调用
-	cleanupHandlers = append(cleanupHandlers, handler)
+// MemoryCgroup 返回进程所在且设置了内存限制的cgroup，没有时返回nil，nil 接收者安全
+func (s *Sandbox) MemoryCgroup() *CgroupManager {
+	if s == nil || s.Cgroup == nil || s.Profile.MemoryLimitBytes <= 0 {
+		return nil
+	}
+	return s.Cgroup
 }
 
-// 保存所有需要执行的清理函数
-var cleanupHandlers []func()
+// Kill 终止cgroup中的所有进程，包括脱离了进程组或被重新指定父进程的后代
+// Close 之后调用不做任何事，nil 接收者安全
+func (s *Sandbox) Kill() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed || s.Cgroup == nil {
+		return nil
+	}
+	return s.Cgroup.Kill()
+}
 
-// Cleanup 执行所有注册的清理函数
-func Cleanup() {
-	for _, handler := range cleanupHandlers {
-		handler()
+// Close 终止残留进程并释放本次执行的安全资源，可重复调用，nil 接收者安全
+func (s *Sandbox) Close() error {
+	if s == nil {
+		return nil
 	}
-	cleanupHandlers = nil
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.closed = true
+		var errs []error
+		if s.Cgroup != nil {
+			// 只有没有进程的cgroup才能删除
+			if err := s.Cgroup.Kill(); err != nil {
+				util.WarnLog("终止cgroup %s 中的进程失败: %v", s.Cgroup.GroupID, err)
+			}
+			if err := CleanupCgroups(s.Cgroup); err != nil {
+				util.ErrorLog("清理cgroup失败: %v", err)
+				errs = append(errs, err)
+			}
+		}
+		if s.statusReader != nil {
+			s.statusReader.Close()
+			s.statusWriter.Close()
+		}
+		if s.rootDir != "" {
+			// 挂载只存在于子进程的命名空间中，这里只剩一个空目录
+			if err := os.Remove(s.rootDir); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		s.closeErr = errors.Join(errs...)
+	})
+	return s.closeErr
 }
@@ -0,0 +1,22 @@
+package util
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// SetCPUAffinity 将进程绑定到指定的CPU核心
+func SetCPUAffinity(pid int, cpu int) error {
+	if cpu < 0 || cpu >= 1024 {
+		return fmt.Errorf("无效的CPU编号: %d", cpu)
+	}
+	var mask [1024 / 64]uint64
+	mask[cpu/64] |= 1 << (uint(cpu) % 64)
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETAFFINITY, uintptr(pid),
+		unsafe.Sizeof(mask), uintptr(unsafe.Pointer(&mask[0])))
+	if errno != 0 {
+		return fmt.Errorf("设置进程 %d 的CPU亲和性失败: %w", pid, errno)
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package util
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// SetCPUAffinity 将进程绑定到指定的CPU核心（仅支持Linux）
+func SetCPUAffinity(pid int, cpu int) error {
+	return fmt.Errorf("不支持在 %s 上设置CPU亲和性", runtime.GOOS)
+}
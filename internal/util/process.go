@@ -15,151 +15,130 @@ import (
 
 // ProcessStats 存储进程的资源使用情况
 type ProcessStats struct {
-	PID        int   // 进程ID
-	MemoryKB   int64 // 内存使用（KB）
-	CPUTimeMS  int64 // CPU使用时间（毫秒）
-	IsExceeded bool  // 是否超过内存限制
-	IsTimeout  bool  // 是否超时
+	PID        int           // 进程ID
+	MemoryKB   int64         // 内存使用（KB）
+	CPUTimeMS  int64         // CPU使用时间（毫秒）
+	IsExceeded bool          // 是否超过内存限制
+	IsTimeout  bool          // 是否超时
 	Duration   time.Duration // 执行时长
 }
 
-// MonitorProcess 监控指定进程的资源使用情况（内存和时间）
-func MonitorProcess(pid int, memoryLimitKB int64, timeoutDuration time.Duration, interval time.Duration, done <-chan struct{}) *ProcessStats {
-	stats := &ProcessStats{
-		PID:      pid,
-		MemoryKB: -1,
+// ProcessMonitor 监控指定进程的资源使用情况（内存和时间）
+// 所有状态只由一个后台goroutine修改，Stop 等待该goroutine退出后返回最终结果，
+// 因此调用方读取的统计数据不会再被并发修改
+type ProcessMonitor struct {
+	pid           int
+	memoryLimitKB int64         // 内存限制，0 表示不检查
+	timeout       time.Duration // 墙钟时间限制
+	interval      time.Duration // 采样间隔
+	kill          func() error  // 超限时终止整个进程树
+
+	stats    ProcessStats
+	stopOnce sync.Once
+	stop     chan struct{} // 通知后台goroutine退出
+	done     chan struct{} // 后台goroutine退出后关闭
+}
+
+// NewProcessMonitor 创建进程监控器，调用 Start 后开始监控
+// 超限时调用 kill 终止整个进程树，kill 为nil时终止进程组及其后代进程
+func NewProcessMonitor(pid int, memoryLimitKB int64, timeout time.Duration, interval time.Duration, kill func() error) *ProcessMonitor {
+	// 确保超时值有效
+	if timeout <= 0 {
+		WarnLog("无效的超时设置: %v, 使用默认值", timeout)
+		timeout = 10 * time.Second // 使用安全的默认值
 	}
+	if kill == nil {
+		kill = func() error { return terminateProcessTree(pid) }
+	}
+	return &ProcessMonitor{
+		pid:           pid,
+		memoryLimitKB: memoryLimitKB,
+		timeout:       timeout,
+		interval:      interval,
+		kill:          kill,
+		stats:         ProcessStats{PID: pid, MemoryKB: -1},
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+}
 
+// Start 在后台开始监控，应在进程启动后立即调用且只调用一次
+func (m *ProcessMonitor) Start() {
 	// 确保进程ID有效
-	if pid <= 0 {
-		ErrorLog("无效的进程ID: %d", pid)
-		return stats
+	if m.pid <= 0 {
+		ErrorLog("无效的进程ID: %d", m.pid)
+		close(m.done)
+		return
 	}
+	DebugLog("开始监控进程 %d，超时设置: %.2f秒", m.pid, m.timeout.Seconds())
+	go m.run(time.Now())
+}
 
-	// 确保超时值有效
-	if timeoutDuration <= 0 {
-		WarnLog("无效的超时设置: %v, 使用默认值", timeoutDuration)
-		timeoutDuration = 10 * time.Second // 使用安全的默认值
-	}
+// Stop 停止监控并返回最终的统计数据，应在进程被回收（Wait返回）之后调用，可重复调用
+func (m *ProcessMonitor) Stop() ProcessStats {
+	m.stopOnce.Do(func() { close(m.stop) })
+	<-m.done
+	return m.stats
+}
+
+// run 监控循环，超时或内存超限时终止进程树后退出，收到停止通知时记录执行时长后退出
+func (m *ProcessMonitor) run(startTime time.Time) {
+	defer close(m.done)
+	defer DebugLog("进程 %d 的监控任务已完成", m.pid)
 
-	DebugLog("开始监控进程 %d，超时设置: %.2f秒", pid, timeoutDuration.Seconds())
-	
-	// 创建同步组，确保资源监控正确完成
-	var wg sync.WaitGroup
-	var mutex sync.Mutex
-	startTime := time.Now()
-	
 	// 创建独立的计时器用于精确超时控制
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		
-		 // 记录实际启动时间
-		timer := time.NewTimer(timeoutDuration)
-		defer timer.Stop()
-		
+	timer := time.NewTimer(m.timeout)
+	defer timer.Stop()
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
 		select {
 		case <-timer.C:
-			mutex.Lock()
 			elapsed := time.Since(startTime)
-			InfoLog("进程 %d 超时: %.2f秒 (限制: %.2f秒)", 
-				pid, elapsed.Seconds(), timeoutDuration.Seconds())
-			stats.IsTimeout = true
-			stats.Duration = elapsed
-			
+			InfoLog("进程 %d 超时: %.2f秒 (限制: %.2f秒)",
+				m.pid, elapsed.Seconds(), m.timeout.Seconds())
+			m.stats.IsTimeout = true
+			m.stats.Duration = elapsed
+
 			// 强制终止进程树
-			killErr := terminateProcessTree(pid)
-			if killErr != nil {
-				ErrorLog("终止进程 %d 时发生错误: %v", pid, killErr)
+			if err := m.kill(); err != nil {
+				ErrorLog("终止进程 %d 时发生错误: %v", m.pid, err)
 			} else {
-				DebugLog("成功终止进程 %d 和其子进程", pid)
+				DebugLog("成功终止进程 %d 和其子进程", m.pid)
 			}
-			mutex.Unlock()
-			
-		case <-done:
-			// 如果通道关闭，退出监控
 			return
-		}
-	}()
-	
-	// 使用独立的goroutine监控资源使用
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		ticker := time.NewTicker(interval)
-		defer ticker.Stop()
-		
-		for {
-			select {
-			case <-ticker.C:
-				mutex.Lock()
-				
-				// 检查是否已超时
-				if stats.IsTimeout {
-					mutex.Unlock()
-					return
-				}
-				
-				// 检查进程是否仍在运行
-				if !isProcessRunning(pid) {
-					stats.Duration = time.Since(startTime)
-					mutex.Unlock()
-					return
-				}
-				
-				// 更新运行时间
-				elapsed := time.Since(startTime)
-				stats.Duration = elapsed
-				
-				 // 定期记录进程状态
-				if DebugMode && int(elapsed.Seconds()) > 0 && 
-				   int(elapsed.Seconds()) % 1 == 0 && 
-				   int(elapsed.Seconds()) != int((elapsed - interval).Seconds()) {
-					DebugLog("进程 %d 已运行: %.1f秒 (限制: %.1f秒)", 
-						pid, elapsed.Seconds(), timeoutDuration.Seconds())
-				}
-				
-				 // 监控内存使用
-				memKB, err := getProcessAndChildrenMemoryKB(pid)
-				if err == nil && memKB > stats.MemoryKB {
-					stats.MemoryKB = memKB
-					
-					// 仅在调试模式下记录内存使用情况
-					if DebugMode && int(elapsed.Seconds()) > 0 && int(elapsed.Seconds()) % 2 == 0 &&
-					   int(elapsed.Seconds()) != int((elapsed - interval).Seconds()) {
-						DebugLog("进程 %d 内存使用: %d KB (%.2f MB)", 
-							pid, memKB, float64(memKB)/1024)
-					}
-				}
-				
-				// 检查内存限制
-				if memoryLimitKB > 0 && stats.MemoryKB > memoryLimitKB {
-					InfoLog("进程 %d 内存超限: %d KB > %d KB", 
-						pid, stats.MemoryKB, memoryLimitKB)
-					stats.IsExceeded = true
-					_ = terminateProcessTree(pid)
-					mutex.Unlock()
-					return
-				}
-				
-				mutex.Unlock()
-				
-			case <-done:
-				mutex.Lock()
-				stats.Duration = time.Since(startTime)
-				mutex.Unlock()
+
+		case <-ticker.C:
+			// 更新运行时间
+			elapsed := time.Since(startTime)
+			m.stats.Duration = elapsed
+
+			// 进程已退出但尚未被回收时不再采样
+			if !isProcessRunning(m.pid) {
+				continue
+			}
+
+			// 监控内存使用
+			memKB, err := getProcessAndChildrenMemoryKB(m.pid)
+			if err == nil && memKB > m.stats.MemoryKB {
+				m.stats.MemoryKB = memKB
+			}
+
+			// 检查内存限制
+			if m.memoryLimitKB > 0 && m.stats.MemoryKB > m.memoryLimitKB {
+				InfoLog("进程 %d 内存超限: %d KB > %d KB",
+					m.pid, m.stats.MemoryKB, m.memoryLimitKB)
+				m.stats.IsExceeded = true
+				_ = m.kill()
 				return
 			}
+
+		case <-m.stop:
+			m.stats.Duration = time.Since(startTime)
+			return
 		}
-	}()
-	
-	// 等待所有监控goroutine完成
-	go func() {
-		wg.Wait()
-		DebugLog("进程 %d 的监控任务已完成", pid)
-	}()
-
-	return stats
+	}
 }
 
 // isProcessRunning 检查进程是否在运行
@@ -168,58 +147,54 @@ func isProcessRunning(pid int) bool {
 	if err != nil {
 		return false
 	}
-	
+
 	// 尝试发送空信号检查进程是否存在
 	err = proc.Signal(syscall.Signal(0))
 	return err == nil
 }
 
-// terminateProcessTree 终止进程及其子进程，改进版本
+// terminateProcessTree 终止进程所在的进程组，以及仍能通过父子关系找到的全部后代进程
 func terminateProcessTree(pid int) error {
-	// 首先尝试获取所有子进程
-	children, err := getChildProcesses(pid)
-	if err == nil && len(children) > 0 {
-		log.Printf("找到 %d 个子进程需要终止", len(children))
-		
-		// 终止所有子进程
-		for _, childPid := range children {
-			proc, err := os.FindProcess(childPid)
-			if err == nil {
-				log.Printf("终止子进程: %d", childPid)
-				if err := proc.Kill(); err != nil {
-					log.Printf("无法终止子进程 %d: %v", childPid, err)
-				}
-				
-				// 在Unix系统上使用SIGKILL确保终止
-				if runtime.GOOS != "windows" {
-					_ = proc.Signal(syscall.SIGKILL)
-				}
-			}
-		}
+	// 先收集后代再终止，避免父进程退出后子进程被重新指定父进程而无法找到
+	descendants := getDescendantProcesses(pid)
+	err := KillProcessGroup(pid)
+	if kerr := syscall.Kill(pid, syscall.SIGKILL); kerr != nil && kerr != syscall.ESRCH {
+		err = fmt.Errorf("终止进程 %d 失败: %w", pid, kerr)
 	}
-	
-	// 最后终止主进程
-	proc, err := os.FindProcess(pid)
-	if err != nil {
-		return fmt.Errorf("找不到进程 %d: %w", pid, err)
+	for _, childPid := range descendants {
+		if kerr := syscall.Kill(childPid, syscall.SIGKILL); kerr != nil && kerr != syscall.ESRCH {
+			log.Printf("无法终止子进程 %d: %v", childPid, kerr)
+		}
 	}
-	
-	log.Printf("终止主进程: %d", pid)
-	if err := proc.Kill(); err != nil {
-		return fmt.Errorf("终止进程 %d 失败: %w", pid, err)
+	return err
+}
+
+// KillProcessGroup 向以 pid 为组长的进程组发送SIGKILL，进程需要以 Setpgid 启动
+// 组内进程全部退出后不返回错误；只要组内还有进程，组ID就不会被新进程复用
+func KillProcessGroup(pid int) error {
+	if pid <= 0 {
+		return fmt.Errorf("无效的进程ID: %d", pid)
 	}
-	
-	// 在Unix系统上使用SIGKILL确保终止
-	if runtime.GOOS != "windows" {
-		_ = proc.Signal(syscall.SIGKILL)
+	if err := syscall.Kill(-pid, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+		return fmt.Errorf("终止进程组 %d 失败: %w", pid, err)
 	}
-	
 	return nil
 }
 
-// MonitorMemory 监控指定进程的内存使用(为兼容性保留)
-func MonitorMemory(pid int, memoryLimitKB int64, interval time.Duration, done <-chan struct{}) *ProcessStats {
-	return MonitorProcess(pid, memoryLimitKB, 0, interval, done)
+// getDescendantProcesses 递归获取指定进程的所有后代进程ID
+func getDescendantProcesses(pid int) []int {
+	var descendants []int
+	queue := []int{pid}
+	for len(queue) > 0 {
+		children, err := getChildProcesses(queue[0])
+		queue = queue[1:]
+		if err != nil {
+			continue
+		}
+		descendants = append(descendants, children...)
+		queue = append(queue, children...)
+	}
+	return descendants
 }
 
 // getProcessMemoryKB 获取指定进程的内存使用量（KB）
@@ -312,7 +287,7 @@ func getProcessAndChildrenMemoryKB(pid int) (int64, error) {
 	if err != nil {
 		return -1, err
 	}
-	
+
 	// 如果是Java或Python等解释型语言，尝试查找子进程
 	children, err := getChildProcesses(pid)
 	if err == nil && len(children) > 0 {
@@ -324,14 +299,14 @@ func getProcessAndChildrenMemoryKB(pid int) (int64, error) {
 			}
 		}
 	}
-	
+
 	return memKB, nil
 }
 
 // getChildProcesses 获取指定进程的所有子进程ID
 func getChildProcesses(pid int) ([]int, error) {
 	var children []int
-	
+
 	switch runtime.GOOS {
 	case "linux":
 		// 在Linux上使用/proc文件系统
@@ -339,7 +314,7 @@ func getChildProcesses(pid int) ([]int, error) {
 		if err != nil {
 			return nil, err
 		}
-		
+
 		for _, file := range files {
 			// 如果是数字，可能是进程ID目录
 			if file.IsDir() {
@@ -360,7 +335,7 @@ func getChildProcesses(pid int) ([]int, error) {
 				}
 			}
 		}
-		
+
 	case "darwin", "windows":
 		// 在macOS和Windows上使用ps命令
 		var cmd *exec.Cmd
@@ -369,12 +344,12 @@ func getChildProcesses(pid int) ([]int, error) {
 		} else {
 			cmd = exec.Command("wmic", "process", "where", fmt.Sprintf("ParentProcessId=%d", pid), "get", "ProcessId")
 		}
-		
+
 		output, err := cmd.Output()
 		if err != nil {
 			return nil, err
 		}
-		
+
 		lines := strings.Split(string(output), "\n")
 		for _, line := range lines {
 			line = strings.TrimSpace(line)
@@ -386,7 +361,7 @@ func getChildProcesses(pid int) ([]int, error) {
 			}
 		}
 	}
-	
+
 	return children, nil
 }
 
@@ -0,0 +1,75 @@
+package util
+
+import (
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProcessMonitorStopDuringSampling(t *testing.T) {
+	cmd := exec.Command("sleep", "10")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot start sleep: %v", err)
+	}
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+
+	// 采样间隔极短，Stop 时后台goroutine几乎一定正在采样
+	monitor := NewProcessMonitor(cmd.Process.Pid, 0, 10*time.Second, time.Microsecond, nil)
+	monitor.Start()
+	time.Sleep(50 * time.Millisecond)
+
+	const stoppers = 8
+	snapshots := make([]ProcessStats, stoppers)
+	var wg sync.WaitGroup
+	for i := 0; i < stoppers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			snapshots[i] = monitor.Stop()
+		}(i)
+	}
+	wg.Wait()
+
+	final := snapshots[0]
+	if final.PID != cmd.Process.Pid || final.Duration < 50*time.Millisecond {
+		t.Errorf("final snapshot %+v: want PID %d and a duration of at least 50ms", final, cmd.Process.Pid)
+	}
+	if final.MemoryKB <= 0 {
+		t.Errorf("final snapshot %+v: memory was never sampled", final)
+	}
+	if final.IsTimeout || final.IsExceeded {
+		t.Errorf("final snapshot %+v: unexpected limit verdict", final)
+	}
+	for i, s := range snapshots {
+		if s != final {
+			t.Errorf("Stop call %d returned %+v, want %+v", i, s, final)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	if s := monitor.Stop(); s != final {
+		t.Errorf("snapshot changed after Stop: %+v, want %+v", s, final)
+	}
+}
+
+func TestProcessMonitorTimeout(t *testing.T) {
+	cmd := exec.Command("sleep", "10")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot start sleep: %v", err)
+	}
+	var killed sync.WaitGroup
+	killed.Add(1)
+	monitor := NewProcessMonitor(cmd.Process.Pid, 0, 100*time.Millisecond, time.Millisecond, func() error {
+		defer killed.Done()
+		return cmd.Process.Kill()
+	})
+	monitor.Start()
+	cmd.Wait()
+	killed.Wait()
+
+	stats := monitor.Stop()
+	if !stats.IsTimeout || stats.Duration < 100*time.Millisecond {
+		t.Errorf("stats %+v: want a timeout after at least 100ms", stats)
+	}
+}
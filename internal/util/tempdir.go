@@ -3,12 +3,14 @@ package util
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strings"
-	"os/exec"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -43,11 +45,11 @@ func SetupHostRunDir(baseDir string) (runDir string, cleanup func(), err error)
 
 // EnsureDir creates a directory if it doesn't exist
 func EnsureDir(dirName string) error {
-    err := os.MkdirAll(dirName, 0755)
-    if err != nil {
-        return fmt.Errorf("failed to create directory %s: %w", dirName, err)
-    }
-    return nil
+	err := os.MkdirAll(dirName, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dirName, err)
+	}
+	return nil
 }
 
 // ProcessCommandString replaces placeholders in a command string with actual values
@@ -65,13 +67,13 @@ func ProcessCommandTemplate(cmdTemplate string, replacements map[string]string)
 	if cmdStr == "" {
 		return nil, fmt.Errorf("empty command after processing")
 	}
-	
+
 	// 简单拆分命令，将命令拆为数组 (不处理复杂引号)
 	cmdParts := strings.Fields(cmdStr)
 	if len(cmdParts) == 0 {
 		return nil, fmt.Errorf("no command parts after splitting")
 	}
-	
+
 	return cmdParts, nil
 }
 
@@ -81,7 +83,7 @@ func CompareOutputs(actual, expected string) bool {
 	// 标准化字符串
 	actual = NormalizeString(actual)
 	expected = NormalizeString(expected)
-	
+
 	return actual == expected
 }
 
@@ -101,5 +103,152 @@ func NormalizeString(s string) string {
 
 // LookPath is a wrapper around exec.LookPath
 func LookPath(file string) (string, error) {
-    return exec.LookPath(file)
-}
\ No newline at end of file
+	return exec.LookPath(file)
+}
+
+// CopyDir recursively copies the contents of src into dst, preserving file modes.
+// dst is created if it does not exist.
+func CopyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		case info.Mode().IsRegular():
+			return copyFile(path, target, info.Mode().Perm())
+		default:
+			// 跳过符号链接、设备等特殊文件
+			return nil
+		}
+	})
+}
+
+// copyFile copies a single regular file
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to copy %s to %s: %w", src, dst, err)
+	}
+	return out.Close()
+}
+
+// DirSize returns the total size in bytes of regular files under dir
+func DirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// ProtectDir removes group and other write permission from dir and everything below it, so only
+// its owner can modify the files. Symlinks are left alone.
+func ProtectDir(dir string) error {
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.Type()&os.ModeSymlink != 0 {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if mode := info.Mode(); mode.Perm()&0022 != 0 {
+			return os.Chmod(path, mode&^0022)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to protect %s: %w", dir, err)
+	}
+	return nil
+}
+
+// ShareDir lets group gid create files in dir without touching the files already there: dir is
+// handed to gid with mode 1770, and the sticky bit stops the group from removing or renaming
+// files it does not own. The owner of dir and of its contents is unchanged.
+func ShareDir(dir string, gid int) error {
+	if err := os.Chown(dir, -1, gid); err != nil {
+		return fmt.Errorf("failed to chown %s to group %d: %w", dir, gid, err)
+	}
+	if err := os.Chmod(dir, 0770|os.ModeSticky); err != nil {
+		return fmt.Errorf("failed to set permissions of %s: %w", dir, err)
+	}
+	return nil
+}
+
+// FileSnapshot records the size and modification time of the regular files under a directory,
+// keyed by path.
+type FileSnapshot map[string]fileState
+
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// SnapshotFiles records the regular files under dir
+func SnapshotFiles(dir string) FileSnapshot {
+	snapshot := FileSnapshot{}
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			snapshot[path] = fileState{size: info.Size(), modTime: info.ModTime()}
+		}
+		return nil
+	})
+	return snapshot
+}
+
+// ChangedFileLargerThan reports whether a regular file under dir that was created or modified
+// since the snapshot is larger than size bytes. Unchanged files (sources, binaries, input files) are ignored.
+func (s FileSnapshot) ChangedFileLargerThan(dir string, size int64) bool {
+	found := false
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || found || !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.Size() <= size {
+			return nil
+		}
+		if old, ok := s[path]; !ok || old.size != info.Size() || !old.modTime.Equal(info.ModTime()) {
+			found = true
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return found
+}